@@ -63,6 +63,30 @@ module "restaurants_table" {
   hash_key_type  = "S"
   read_capacity  = 10
   write_capacity = 5
+
+  // Index keys derived by the server on write (see services/store.go).
+  // kosher_key is only set on kosher restaurants, so that index is sparse.
+  attributes = [
+    { name = "cuisine_key", type = "S" },
+    { name = "kosher_key", type = "S" },
+  ]
+
+  global_secondary_indexes = [
+    {
+      name            = "cuisine_key-index"
+      hash_key        = "cuisine_key"
+      projection_type = "ALL"
+      read_capacity   = 10
+      write_capacity  = 5
+    },
+    {
+      name            = "kosher_key-index"
+      hash_key        = "kosher_key"
+      projection_type = "ALL"
+      read_capacity   = 10
+      write_capacity  = 5
+    },
+  ]
 }
 
 module "audit_logs_table" {
@@ -75,6 +99,34 @@ module "audit_logs_table" {
   write_capacity = 5
 }
 
+module "reviews_table" {
+  source         = "./modules/dynamodb"
+  table_name     = "reviews"
+  billing_mode   = "PROVISIONED"
+  hash_key       = "restaurant_id"
+  hash_key_type  = "S"
+  range_key      = "review_id" // time-ordered, so reviews are listed newest first
+  range_key_type = "S"
+  read_capacity  = 10
+  write_capacity = 5
+
+  // Set only on pending reviews, so the index holds just the moderation queue (see services/review_store.go)
+  attributes = [
+    { name = "pending_key", type = "S" },
+  ]
+
+  global_secondary_indexes = [
+    {
+      name            = "pending_key-index"
+      hash_key        = "pending_key"
+      range_key       = "review_id"
+      projection_type = "ALL"
+      read_capacity   = 5
+      write_capacity  = 5
+    },
+  ]
+}
+
 // ECR Repository
 
 module "ecr" {
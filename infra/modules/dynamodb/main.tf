@@ -2,6 +2,7 @@ resource "aws_dynamodb_table" "this" {
   name           = var.table_name
   billing_mode   = var.billing_mode
   hash_key       = var.hash_key
+  range_key      = var.range_key
   read_capacity  = var.read_capacity
   write_capacity = var.write_capacity
 
@@ -9,4 +10,32 @@ resource "aws_dynamodb_table" "this" {
     name = var.hash_key
     type = var.hash_key_type
   }
+
+  dynamic "attribute" {
+    for_each = var.range_key == null ? [] : [var.range_key]
+    content {
+      name = attribute.value
+      type = var.range_key_type
+    }
+  }
+
+  dynamic "attribute" {
+    for_each = var.attributes
+    content {
+      name = attribute.value.name
+      type = attribute.value.type
+    }
+  }
+
+  dynamic "global_secondary_index" {
+    for_each = var.global_secondary_indexes
+    content {
+      name            = global_secondary_index.value.name
+      hash_key        = global_secondary_index.value.hash_key
+      range_key       = global_secondary_index.value.range_key
+      projection_type = global_secondary_index.value.projection_type
+      read_capacity   = var.billing_mode == "PROVISIONED" ? global_secondary_index.value.read_capacity : null
+      write_capacity  = var.billing_mode == "PROVISIONED" ? global_secondary_index.value.write_capacity : null
+    }
+  }
 }
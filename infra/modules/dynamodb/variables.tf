@@ -20,6 +20,18 @@ variable "hash_key_type" {
   default     = "S"
 }
 
+variable "range_key" {
+  description = "Optional range (sort) key for the table"
+  type        = string
+  default     = null
+}
+
+variable "range_key_type" {
+  description = "Range key type (S for String, N for Number, B for Binary)"
+  type        = string
+  default     = "S"
+}
+
 variable "read_capacity" {
   description = "Read capacity units (only for PROVISIONED mode)"
   type        = number
@@ -31,3 +43,25 @@ variable "write_capacity" {
   type        = number
   default     = 5
 }
+
+variable "attributes" {
+  description = "Additional attributes used as index keys"
+  type = list(object({
+    name = string
+    type = string
+  }))
+  default = []
+}
+
+variable "global_secondary_indexes" {
+  description = "Global secondary indexes (capacities are only used in PROVISIONED mode)"
+  type = list(object({
+    name            = string
+    hash_key        = string
+    range_key       = optional(string)
+    projection_type = string
+    read_capacity   = number
+    write_capacity  = number
+  }))
+  default = []
+}
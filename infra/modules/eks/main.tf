@@ -37,11 +37,15 @@ resource "aws_iam_policy" "eks_dynamodb_access" {
           "dynamodb:Scan",
           "dynamodb:Query",
           "dynamodb:UpdateItem",
-          "dynamodb:DeleteItem"
+          "dynamodb:DeleteItem",
+          "dynamodb:BatchWriteItem"
         ],
         Resource = [
           "arn:aws:dynamodb:us-east-1:${var.account_id}:table/restaurants",
-          "arn:aws:dynamodb:us-east-1:${var.account_id}:table/audit_logs"
+          "arn:aws:dynamodb:us-east-1:${var.account_id}:table/restaurants/index/*",
+          "arn:aws:dynamodb:us-east-1:${var.account_id}:table/audit_logs",
+          "arn:aws:dynamodb:us-east-1:${var.account_id}:table/reviews",
+          "arn:aws:dynamodb:us-east-1:${var.account_id}:table/reviews/index/*"
         ]
       }
     ]
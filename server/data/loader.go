@@ -1,10 +1,13 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"os"
 
 	"server/models"
+	"server/services"
 )
 
 func LoadRestaurants(filename string) ([]models.Restaurant, error) {
@@ -23,3 +26,16 @@ func LoadRestaurants(filename string) ([]models.Restaurant, error) {
 
 	return restaurants, nil
 }
+
+func InsertRestaurants(ctx context.Context, store services.RestaurantStore, restaurants []models.Restaurant) error {
+	for _, restaurant := range restaurants {
+		restaurant.Touch()
+		err := store.PutRestaurant(ctx, restaurant)
+		if err != nil {
+			log.Printf("Failed to insert restaurant %s: %v", restaurant.Name, err)
+			return err
+		}
+		log.Printf("Inserted restaurant: %s", restaurant.Name)
+	}
+	return nil
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"server/models"
 	"server/services"
+	"server/utils"
 
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -18,12 +21,17 @@ func generateUniqueID() string {
 	return uuid.New().String()
 }
 
-func AddRestaurant(c *gin.Context, client *dynamodb.Client) {
+func AddRestaurant(c *gin.Context, store services.RestaurantStore) {
 	var restaurant models.Restaurant
 
 	if err := c.ShouldBindJSON(&restaurant); err != nil {
 		log.Printf("Error binding JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid restaurant data", "details": err.Error()})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid restaurant data", err.Error())
+		return
+	}
+
+	if err := services.ValidateRestaurant(restaurant); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid restaurant data", err.Error())
 		return
 	}
 
@@ -34,66 +42,186 @@ func AddRestaurant(c *gin.Context, client *dynamodb.Client) {
 	log.Printf("Restaurant to be added: %+v", restaurant)
 
 	// Call the service to add the restaurant
-	err := services.AddRestaurant(c.Request.Context(), client, "restaurants", restaurant)
+	err := services.AddRestaurant(c.Request.Context(), store, restaurant)
 	if err != nil {
 		log.Printf("Error inserting restaurant: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add restaurant", "details": err.Error()})
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to add restaurant", err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Restaurant added successfully"})
+	utils.Respond(c, http.StatusOK, gin.H{"message": "Restaurant added successfully"})
 }
 
-func RemoveRestaurant(c *gin.Context, client *dynamodb.Client) {
+func RemoveRestaurant(c *gin.Context, store services.RestaurantStore, reviews services.ReviewStore) {
 	restaurantID := c.Param("id")
 
-	// Remove the restaurant from DynamoDB
-	err := services.RemoveRestaurant(c.Request.Context(), client, "restaurants", restaurantID)
+	// Remove the restaurant from the store
+	err := services.RemoveRestaurant(c.Request.Context(), store, reviews, restaurantID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove restaurant"})
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to remove restaurant")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Restaurant removed successfully"})
+	utils.Respond(c, http.StatusOK, gin.H{"message": "Restaurant removed successfully"})
 }
 
-func EditRestaurant(c *gin.Context, client *dynamodb.Client) {
+func EditRestaurant(c *gin.Context, store services.RestaurantStore) {
 	restaurantID := c.Param("id")
 	var restaurant models.Restaurant
 
 	// Bind JSON payload to restaurant struct
 	if err := c.ShouldBindJSON(&restaurant); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid restaurant data"})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid restaurant data")
+		return
+	}
+
+	if err := services.ValidateRestaurant(restaurant); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid restaurant data", err.Error())
 		return
 	}
 
 	restaurant.RestaurantID = restaurantID // Ensure the correct restaurant_id is set
 
-	// Update the restaurant in DynamoDB
-	err := services.EditRestaurant(c.Request.Context(), client, "restaurants", restaurant)
+	// Update the restaurant in the store
+	err := services.EditRestaurant(c.Request.Context(), store, restaurant)
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to edit restaurant")
+		return
+	}
+
+	utils.Respond(c, http.StatusOK, gin.H{"message": "Restaurant updated successfully"})
+}
+
+func GetRestaurantByID(c *gin.Context, store services.RestaurantStore) {
+	restaurantID := c.Param("id")
+	restaurant, err := services.FetchRestaurantByID(c.Request.Context(), store, restaurantID)
+
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to edit restaurant"})
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch restaurant details")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Restaurant updated successfully"})
+	utils.Respond(c, http.StatusOK, restaurant)
 }
 
-func GetRestaurantByID(c *gin.Context, client *dynamodb.Client) {
+type specialHoursUpdate struct {
+	Hours *string `json:"hours" binding:"required"` // e.g. "10:00-14:00" or "Closed"
+	Note  string  `json:"note"`
+}
+
+func SetSpecialHours(c *gin.Context, store services.RestaurantStore) {
 	restaurantID := c.Param("id")
-	restaurant, err := services.FetchRestaurantByID(c.Request.Context(), client, "restaurants", restaurantID)
+	date := c.Param("date")
 
+	if _, err := time.Parse(models.DateLayout, date); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid date. Must be YYYY-MM-DD.")
+		return
+	}
+
+	var update specialHoursUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid special hours data", err.Error())
+		return
+	}
+	// Missing hours must not silently close the restaurant for the day
+	if strings.TrimSpace(*update.Hours) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid special hours data", "hours is required; use \"Closed\" to close for the day")
+		return
+	}
+	hours, err := models.ParseDaySchedule(*update.Hours)
+	if err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid special hours data", err.Error())
+		return
+	}
+	special := models.SpecialHours{Date: date, Hours: hours, Note: update.Note}
+
+	restaurant, err := services.SetSpecialHours(c.Request.Context(), store, restaurantID, special)
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restaurant details"})
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update special hours")
 		return
 	}
 
-	if restaurant == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+	utils.Respond(c, http.StatusOK, restaurant)
+}
+
+func RemoveSpecialHours(c *gin.Context, store services.RestaurantStore) {
+	restaurantID := c.Param("id")
+	date := c.Param("date")
+
+	restaurant, err := services.RemoveSpecialHours(c.Request.Context(), store, restaurantID, date)
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to remove special hours")
+		return
+	}
+
+	utils.Respond(c, http.StatusOK, restaurant)
+}
+
+type statusUpdate struct {
+	Status       string `json:"status" binding:"required"`
+	StatusReason string `json:"status_reason"`
+	ReopenDate   string `json:"reopen_date"`
+}
+
+func SetRestaurantStatus(c *gin.Context, store services.RestaurantStore) {
+	restaurantID := c.Param("id")
+
+	var update statusUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid status data", err.Error())
+		return
+	}
+	if err := services.ValidateStatus(update.Status, update.ReopenDate); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid status data", err.Error())
+		return
+	}
+
+	restaurant, err := services.SetRestaurantStatus(c.Request.Context(), store, restaurantID, update.Status, update.StatusReason, update.ReopenDate)
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update restaurant status")
+		return
+	}
+
+	utils.Respond(c, http.StatusOK, restaurant)
+}
+
+// GetExpiringCertifications lists kosher certificates expiring within the next 'days' days (default 30).
+func GetExpiringCertifications(c *gin.Context, store services.RestaurantStore) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid 'days' parameter. It must be a non-negative integer.")
+		return
+	}
+
+	expiring, err := services.ExpiringCertifications(c.Request.Context(), store, days, time.Now())
+	if err != nil {
+		log.Printf("Error listing expiring certifications: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch kosher certifications")
 		return
 	}
 
-	c.JSON(http.StatusOK, restaurant)
+	utils.Respond(c, http.StatusOK, expiring)
 }
 
 // AdminAuthMiddleware protects admin routes with a password
@@ -106,13 +234,13 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		expectedPassword := os.Getenv("ADMIN_PASSWORD")
 		log.Printf("Expected admin password: %s", expectedPassword)
 		if expectedPassword == "" {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server is not configured properly"})
+			utils.RespondError(c, http.StatusInternalServerError, "Server is not configured properly")
 			return
 		}
 
 		// Check if the provided password matches
 		if providedPassword != expectedPassword {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			utils.RespondError(c, http.StatusUnauthorized, "Unauthorized")
 			return
 		}
 
@@ -121,7 +249,7 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-func GetAuditLogsHandler(client *dynamodb.Client) gin.HandlerFunc {
+func GetAuditLogsHandler(auditStore services.AuditStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Parse the 'minutes' query parameter (optional)
 		minutesParam := c.Query("minutes")
@@ -131,19 +259,19 @@ func GetAuditLogsHandler(client *dynamodb.Client) gin.HandlerFunc {
 			var err error
 			minutes, err = strconv.Atoi(minutesParam)
 			if err != nil || minutes < 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'minutes' parameter. It must be a positive integer."})
+				utils.RespondError(c, http.StatusBadRequest, "Invalid 'minutes' parameter. It must be a positive integer.")
 				return
 			}
 		}
 
 		// Call the service function to fetch logs
-		logs, err := services.GetFilteredLogs(c.Request.Context(), client, minutes) // Correctly call the function from the `services` package
+		logs, err := services.GetFilteredLogs(c.Request.Context(), auditStore, minutes) // Correctly call the function from the `services` package
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+			utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch audit logs")
 			return
 		}
 
 		// Return the logs as JSON
-		c.JSON(http.StatusOK, logs)
+		utils.Respond(c, http.StatusOK, logs)
 	}
 }
@@ -1,52 +1,347 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"server/models"
 	"server/services"
+	"server/utils"
 
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/gin-gonic/gin"
 )
 
-func SearchRestaurants(c *gin.Context, client *dynamodb.Client) {
+func SearchRestaurants(c *gin.Context, store services.RestaurantStore) {
 	// Get query parameters
-	cuisine := c.Query("cuisine")
+	cuisines := parseList(c.Query("cuisine"))
+	excludeCuisines := parseList(c.Query("exclude_cuisine"))
+	cities := parseList(c.Query("city"))
+	excludeCities := parseList(c.Query("exclude_city"))
+	countries := parseList(c.Query("country"))
+	excludeCountries := parseList(c.Query("exclude_country"))
+	query := c.Query("q")
 	isKosher := c.Query("is_kosher")
+	dietaryTags := parseList(c.Query("dietary"))
 	isOpen := c.Query("is_open")
+	openAt := c.Query("open_at")
+	openFor := c.Query("open_for")
+	includeInactive := c.Query("include_inactive")
+	limit := c.Query("limit")
+	cursor := c.Query("cursor")
+	sortBy := c.Query("sort")
+	order := c.DefaultQuery("order", "asc")
+	lat := c.Query("lat")
+	lng := c.Query("lng")
+	radiusKm := c.Query("radius_km")
+	minPrice := c.Query("min_price")
+	maxPrice := c.Query("max_price")
+	minRating := c.Query("min_rating")
 
 	// Validate query parameters
 	if isKosher != "" && isKosher != "true" && isKosher != "false" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for 'is_kosher'. Must be 'true' or 'false'."})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'is_kosher'. Must be 'true' or 'false'.")
 		return
 	}
+	for _, tag := range dietaryTags {
+		if !models.IsDietaryTag(tag) {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'dietary'. Must be one or more of '%s'.", strings.Join(models.DietaryTags, "', '")))
+			return
+		}
+	}
 	if isOpen != "" && isOpen != "true" && isOpen != "false" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for 'is_open'. Must be 'true' or 'false'."})
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'is_open'. Must be 'true' or 'false'.")
+		return
+	}
+
+	if includeInactive != "" && includeInactive != "true" && includeInactive != "false" {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'include_inactive'. Must be 'true' or 'false'.")
+		return
+	}
+	if openAt != "" && isOpen == "true" {
+		utils.RespondError(c, http.StatusBadRequest, "'open_at' cannot be combined with 'is_open=true'.")
+		return
+	}
+	if openFor != "" && openAt == "" && isOpen != "true" {
+		utils.RespondError(c, http.StatusBadRequest, "'open_for' requires 'open_at' or 'is_open=true'.")
+		return
+	}
+	if (lat == "") != (lng == "") {
+		utils.RespondError(c, http.StatusBadRequest, "Both 'lat' and 'lng' must be provided together.")
+		return
+	}
+	if radiusKm != "" && lat == "" {
+		utils.RespondError(c, http.StatusBadRequest, "'radius_km' requires 'lat' and 'lng'.")
 		return
 	}
 
 	// Create filters
 	filters := services.SearchFilters{
-		Cuisine:  cuisine,
 		IsKosher: isKosher,
 		IsOpen:   isOpen,
+		Query:    query,
+
+		DietaryTags: dietaryTags,
+
+		Cuisines:         cuisines,
+		ExcludeCuisines:  excludeCuisines,
+		Cities:           cities,
+		ExcludeCities:    excludeCities,
+		Countries:        countries,
+		ExcludeCountries: excludeCountries,
+
+		IncludeInactive: includeInactive == "true",
+	}
+
+	if openAt != "" {
+		at, local, err := parseOpenAt(openAt)
+		if err != nil {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'open_at'. Use YYYY-MM-DDTHH:MM, optionally with a UTC offset.")
+			return
+		}
+		filters.OpenAt = &at
+		filters.OpenAtLocal = local
+	}
+	if openFor != "" {
+		duration, err := time.ParseDuration(openFor)
+		if err != nil || duration <= 0 {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'open_for'. Must be a positive duration such as '2h' or '90m'.")
+			return
+		}
+		filters.OpenFor = duration
+	}
+	if lat != "" {
+		latitude, err := strconv.ParseFloat(lat, 64)
+		if err != nil || latitude < -90 || latitude > 90 {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'lat'. Must be a number between -90 and 90.")
+			return
+		}
+		longitude, err := strconv.ParseFloat(lng, 64)
+		if err != nil || longitude < -180 || longitude > 180 {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'lng'. Must be a number between -180 and 180.")
+			return
+		}
+		filters.Near = &models.Location{Latitude: latitude, Longitude: longitude}
+	}
+	if radiusKm != "" {
+		radius, err := strconv.ParseFloat(radiusKm, 64)
+		if err != nil || radius <= 0 {
+			utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'radius_km'. Must be a positive number.")
+			return
+		}
+		filters.RadiusKm = radius
+	}
+
+	if minPrice != "" {
+		level, err := strconv.Atoi(minPrice)
+		if err != nil || level < models.MinPriceLevel || level > models.MaxPriceLevel {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'min_price'. Must be between %d and %d.", models.MinPriceLevel, models.MaxPriceLevel))
+			return
+		}
+		filters.MinPrice = level
+	}
+	if maxPrice != "" {
+		level, err := strconv.Atoi(maxPrice)
+		if err != nil || level < models.MinPriceLevel || level > models.MaxPriceLevel {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'max_price'. Must be between %d and %d.", models.MinPriceLevel, models.MaxPriceLevel))
+			return
+		}
+		filters.MaxPrice = level
+	}
+	if filters.MinPrice > 0 && filters.MaxPrice > 0 && filters.MinPrice > filters.MaxPrice {
+		utils.RespondError(c, http.StatusBadRequest, "'min_price' cannot be greater than 'max_price'.")
+		return
+	}
+	if minRating != "" {
+		rating, err := strconv.ParseFloat(minRating, 64)
+		if err != nil || rating < models.MinRating || rating > models.MaxRating {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'min_rating'. Must be a number between %d and %d.", models.MinRating, models.MaxRating))
+			return
+		}
+		filters.MinRating = rating
+	}
+
+	if order != "asc" && order != "desc" {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'order'. Must be 'asc' or 'desc'.")
+		return
+	}
+	switch sortBy {
+	case "", services.SortName, services.SortCuisine, services.SortClosing, services.SortPrice, services.SortRating:
+	case services.SortRelevance:
+		if strings.TrimSpace(query) == "" {
+			utils.RespondError(c, http.StatusBadRequest, "'sort=relevance' requires 'q'.")
+			return
+		}
+	case services.SortDistance:
+		if lat == "" {
+			utils.RespondError(c, http.StatusBadRequest, "'sort=distance' requires 'lat' and 'lng'.")
+			return
+		}
+	default:
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'sort'. Must be one of 'relevance', 'name', 'cuisine', 'distance', 'closing', 'price' or 'rating'.")
+		return
+	}
+
+	// Clients of the original API that do not paginate get every match, as
+	// they did before pagination was added
+	unpaged := !utils.IsV1(c) && limit == "" && cursor == ""
+	page := services.PageRequest{Cursor: cursor, Sort: sortBy, Descending: order == "desc", All: unpaged}
+	if limit != "" {
+		pageSize, err := strconv.Atoi(limit)
+		if err != nil || pageSize < 1 || pageSize > services.MaxPageSize {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'limit'. Must be between 1 and %d.", services.MaxPageSize))
+			return
+		}
+		page.Limit = pageSize
 	}
 
 	// Call service function
-	restaurants, err := services.SearchRestaurants(c.Request.Context(), client, "restaurants", filters)
+	results, err := services.SearchRestaurants(c.Request.Context(), store, filters, page)
+	if errors.Is(err, services.ErrInvalidSort) {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'sort'.")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCursor) {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'cursor'.")
+		return
+	}
 	if err != nil {
 		log.Printf("Error searching restaurants: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restaurants. Please try again later."})
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch restaurants. Please try again later.")
 		return
 	}
 
-	// Handle empty results
-	if len(restaurants) == 0 {
+	// Handle empty results. /v1 answers with an empty page instead.
+	if len(results.Results) == 0 && !utils.IsV1(c) {
 		c.JSON(http.StatusNotFound, gin.H{"message": "No restaurants match the given criteria."})
 		return
 	}
 
 	// Return successful response
-	c.JSON(http.StatusOK, restaurants)
+	if unpaged {
+		c.JSON(http.StatusOK, results.Results)
+		return
+	}
+	utils.RespondPage(c, results, results.Results, results.NextCursor, gin.H{"facets": results.Facets})
+}
+
+// GetRestaurant returns one restaurant with its current opening status. The
+// ETag covers the opening status too, so clients revalidate with
+// If-None-Match or If-Modified-Since and get 304 while nothing has changed.
+func GetRestaurant(c *gin.Context, store services.RestaurantStore) {
+	restaurant, err := services.FetchRestaurantByID(c.Request.Context(), store, c.Param("id"))
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching restaurant: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch restaurant details")
+		return
+	}
+
+	body, err := json.Marshal(utils.Wrap(c, services.NewRestaurantResult(*restaurant, time.Now())))
+	if err != nil {
+		log.Printf("Error encoding restaurant: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch restaurant details")
+		return
+	}
+	etag := bodyETag(body)
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if restaurant.UpdatedAt != nil {
+		c.Header("Last-Modified", restaurant.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c, *restaurant, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// notModified evaluates the request's conditional headers. If-None-Match takes
+// precedence over If-Modified-Since, as in RFC 9110.
+func notModified(c *gin.Context, restaurant models.Restaurant, etag string) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	if err != nil || restaurant.UpdatedAt == nil || restaurant.UpdatedAt.After(since) {
+		return false
+	}
+
+	// The stored restaurant is unchanged, but its opening status may not be,
+	// so the response as of that date must match the current one
+	body, err := json.Marshal(utils.Wrap(c, services.NewRestaurantResult(restaurant, since)))
+	return err == nil && bodyETag(body) == etag
+}
+
+// SuggestRestaurants completes a typed prefix to restaurant names, cuisines and cities.
+func SuggestRestaurants(c *gin.Context, suggester services.Suggester) {
+	prefix := c.Query("prefix")
+	limit := c.Query("limit")
+
+	if strings.TrimSpace(prefix) == "" {
+		utils.RespondError(c, http.StatusBadRequest, "'prefix' is required.")
+		return
+	}
+
+	count := services.DefaultSuggestLimit
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxSuggestLimit {
+			utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'limit'. Must be between 1 and %d.", services.MaxSuggestLimit))
+			return
+		}
+		count = n
+	}
+
+	utils.Respond(c, http.StatusOK, gin.H{"suggestions": suggester.Suggest(prefix, count)})
+}
+
+// parseList splits a comma-separated parameter such as "Thai,Japanese" into its non-empty values.
+func parseList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// parseOpenAt parses the 'open_at' parameter. A time with a UTC offset is an
+// absolute instant; one without is a wall-clock time at each restaurant.
+func parseOpenAt(value string) (time.Time, bool, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at, false, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at, true, nil
+		}
+	}
+	return time.Time{}, false, errors.New("invalid time")
 }
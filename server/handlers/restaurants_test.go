@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/models"
+	"server/services"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	updated := time.Date(2026, time.March, 2, 12, 0, 0, 0, time.UTC)
+	// Never open, so its opening status is the same at any time
+	closed := models.Restaurant{RestaurantID: "1", Timezone: "UTC", UpdatedAt: &updated}
+	// Open on Mondays, so its next opening as of the update is long past
+	monday, err := models.ParseDaySchedule("09:00-10:00")
+	if err != nil {
+		t.Fatalf("ParseDaySchedule: %v", err)
+	}
+	mondays := closed
+	mondays.OpeningHours = models.WeeklySchedule{time.Monday: monday}
+	unstamped := closed
+	unstamped.UpdatedAt = nil
+
+	since := func(d time.Duration) string {
+		return updated.Add(d).Format(http.TimeFormat)
+	}
+
+	tests := []struct {
+		name       string
+		restaurant models.Restaurant
+		headers    map[string]string
+		want       bool
+	}{
+		{name: "unconditional", restaurant: closed, want: false},
+		{name: "matching ETag", restaurant: closed, headers: map[string]string{"If-None-Match": "{etag}"}, want: true},
+		{name: "weak ETag", restaurant: closed, headers: map[string]string{"If-None-Match": "W/{etag}"}, want: true},
+		{name: "ETag in a list", restaurant: closed, headers: map[string]string{"If-None-Match": `"other", {etag}`}, want: true},
+		{name: "any ETag", restaurant: closed, headers: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "other ETag", restaurant: closed, headers: map[string]string{"If-None-Match": `"other"`}, want: false},
+		{
+			name:       "ETag wins over the date",
+			restaurant: closed,
+			headers:    map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": since(time.Hour)},
+			want:       false,
+		},
+		{name: "unchanged since", restaurant: closed, headers: map[string]string{"If-Modified-Since": since(time.Hour)}, want: true},
+		{name: "unchanged since the update", restaurant: closed, headers: map[string]string{"If-Modified-Since": since(0)}, want: true},
+		{name: "changed since", restaurant: closed, headers: map[string]string{"If-Modified-Since": since(-time.Hour)}, want: false},
+		{name: "opening status changed since", restaurant: mondays, headers: map[string]string{"If-Modified-Since": since(time.Hour)}, want: false},
+		{name: "no update time", restaurant: unstamped, headers: map[string]string{"If-Modified-Since": since(time.Hour)}, want: false},
+		{name: "invalid date", restaurant: closed, headers: map[string]string{"If-Modified-Since": "yesterday"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/restaurants/1", nil)
+
+			body, err := json.Marshal(utils.Wrap(c, services.NewRestaurantResult(tt.restaurant, time.Now())))
+			if err != nil {
+				t.Fatalf("encoding restaurant: %v", err)
+			}
+			etag := bodyETag(body)
+			for name, value := range tt.headers {
+				c.Request.Header.Set(name, strings.ReplaceAll(value, "{etag}", etag))
+			}
+
+			if got := notModified(c, tt.restaurant, etag); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"server/models"
+	"server/services"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type reviewInput struct {
+	Rating int    `json:"rating" binding:"required"`
+	Text   string `json:"text"`
+	Author string `json:"author" binding:"required"`
+}
+
+func AddReview(c *gin.Context, store services.RestaurantStore, reviews services.ReviewStore) {
+	var input reviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid review data", err.Error())
+		return
+	}
+
+	review := models.Review{
+		RestaurantID: c.Param("id"),
+		Rating:       input.Rating,
+		Text:         input.Text,
+		Author:       input.Author,
+	}
+	if err := services.ValidateReview(review); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid review data", err.Error())
+		return
+	}
+
+	review, err := services.AddReview(c.Request.Context(), store, reviews, review)
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error adding review: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to add review")
+		return
+	}
+
+	utils.Respond(c, http.StatusCreated, review)
+}
+
+// parseReviewLimit reads the optional 'limit' query parameter, returning 0
+// for the default page size. It responds with 400 and returns false if the
+// value is invalid.
+func parseReviewLimit(c *gin.Context) (int, bool) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 || n > services.MaxReviewPageSize {
+		utils.RespondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid value for 'limit'. Must be between 1 and %d.", services.MaxReviewPageSize))
+		return 0, false
+	}
+	return n, true
+}
+
+// ListReviews returns a restaurant's approved reviews, newest first.
+func ListReviews(c *gin.Context, store services.RestaurantStore, reviews services.ReviewStore) {
+	pageSize, ok := parseReviewLimit(c)
+	if !ok {
+		return
+	}
+
+	page, err := services.ListReviews(c.Request.Context(), store, reviews, c.Param("id"), pageSize, c.Query("cursor"))
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCursor) {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'cursor'.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing reviews: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch reviews")
+		return
+	}
+
+	utils.RespondPage(c, page, page.Reviews, page.NextCursor, nil)
+}
+
+// ListPendingReviews returns the moderation queue, oldest first.
+func ListPendingReviews(c *gin.Context, reviews services.ReviewStore) {
+	pageSize, ok := parseReviewLimit(c)
+	if !ok {
+		return
+	}
+
+	page, err := services.ListPendingReviews(c.Request.Context(), reviews, pageSize, c.Query("cursor"))
+	if errors.Is(err, services.ErrInvalidCursor) {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid value for 'cursor'.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing pending reviews: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch pending reviews")
+		return
+	}
+
+	utils.RespondPage(c, page, page.Reviews, page.NextCursor, nil)
+}
+
+type reviewStatusUpdate struct {
+	Status       string `json:"status" binding:"required"`
+	StatusReason string `json:"status_reason"`
+}
+
+// SetReviewStatus approves or rejects a pending review.
+func SetReviewStatus(c *gin.Context, store services.RestaurantStore, reviews services.ReviewStore) {
+	var update reviewStatusUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid status data", err.Error())
+		return
+	}
+	if err := services.ValidateReviewStatus(update.Status, update.StatusReason); err != nil {
+		utils.RespondError(c, http.StatusBadRequest, "Invalid status data", err.Error())
+		return
+	}
+
+	review, err := services.ModerateReview(c.Request.Context(), store, reviews, c.Param("id"), c.Param("review_id"), update.Status, update.StatusReason)
+	if errors.Is(err, services.ErrReviewNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Review not found")
+		return
+	}
+	if errors.Is(err, services.ErrRestaurantNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Restaurant not found")
+		return
+	}
+	if errors.Is(err, services.ErrReviewAlreadyModerated) {
+		utils.RespondError(c, http.StatusConflict, "Review has already been moderated")
+		return
+	}
+	if err != nil {
+		log.Printf("Error moderating review: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to update review status")
+		return
+	}
+
+	utils.Respond(c, http.StatusOK, review)
+}
+
+// DeleteReview lets an admin remove a review, for example one that breaks the review guidelines.
+func DeleteReview(c *gin.Context, store services.RestaurantStore, reviews services.ReviewStore) {
+	err := services.DeleteReview(c.Request.Context(), store, reviews, c.Param("id"), c.Param("review_id"))
+	if errors.Is(err, services.ErrReviewNotFound) {
+		utils.RespondError(c, http.StatusNotFound, "Review not found")
+		return
+	}
+	if errors.Is(err, services.ErrReviewChanged) {
+		utils.RespondError(c, http.StatusConflict, "Review was changed while deleting it, try again")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting review: %v", err)
+		utils.RespondError(c, http.StatusInternalServerError, "Failed to delete review")
+		return
+	}
+
+	utils.Respond(c, http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
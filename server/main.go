@@ -2,17 +2,21 @@ package main
 
 import (
 	"context"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
+	_ "time/tzdata" // Embed the timezone database; the runtime image has no zoneinfo
 
 	"server/data"
 	"server/handlers"
 	"server/middleware"
 	"server/services"
+	"server/utils"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -20,20 +24,51 @@ import (
 )
 
 var (
-	svc           *dynamodb.Client
-	tableName     = "restaurants"
-	adminPassword string
+	svc             *dynamodb.Client
+	restaurantStore services.RestaurantStore
+	auditStore      services.AuditStore
+	reviewStore     services.ReviewStore
+	tableName       = "restaurants"
+	storeBackend    = "dynamodb"
+	auditBackend    = "dynamodb"
+	auditTableName  = "audit_logs"
+	auditLogFile    = "audit_logs.jsonl"
+	reviewBackend   = "dynamodb"
+	reviewTableName = "reviews"
+	adminPassword   string
+	searchIndex     *services.IndexedRestaurantStore
+	indexRefresh    = 5 * time.Minute
 )
 
+// The original unversioned routes are deprecated since the /v1 API was
+// introduced and will be removed at the sunset date.
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// auditMemoryCapacity is the number of entries kept by the in-memory audit store.
+const auditMemoryCapacity = 10000
+
 func init() {
 	// Load environment variables
 	loadEnvironmentVariables()
 
-	// Initialize the DynamoDB client
-	initializeDynamoDB()
+	// Initialize the DynamoDB client if any store needs it
+	if storeBackend == "dynamodb" || auditBackend == "dynamodb" || reviewBackend == "dynamodb" {
+		initializeDynamoDB()
+	}
+
+	// Initialize the restaurant, audit and review stores
+	initializeRestaurantStore()
+	initializeAuditStore()
+	initializeReviewStore()
 
 	// Populate the table if it is empty
 	populateTableIfEmpty()
+
+	// Serve searches from an in-memory index over the store
+	initializeSearchIndex()
 }
 
 func loadEnvironmentVariables() {
@@ -48,6 +83,42 @@ func loadEnvironmentVariables() {
 		tableName = envTableName
 	}
 	log.Printf("Using table name: %s", tableName)
+
+	// Optionally select the restaurant storage backend (dynamodb or memory)
+	if envStoreBackend := os.Getenv("RESTAURANT_STORE"); envStoreBackend != "" {
+		storeBackend = envStoreBackend
+	}
+	log.Printf("Using restaurant store: %s", storeBackend)
+
+	// Optionally select the audit log backend (dynamodb, memory or file)
+	if envAuditBackend := os.Getenv("AUDIT_STORE"); envAuditBackend != "" {
+		auditBackend = envAuditBackend
+	}
+	if envAuditTableName := os.Getenv("AUDIT_LOGS_TABLE"); envAuditTableName != "" {
+		auditTableName = envAuditTableName
+	}
+	if envAuditLogFile := os.Getenv("AUDIT_LOG_FILE"); envAuditLogFile != "" {
+		auditLogFile = envAuditLogFile
+	}
+	log.Printf("Using audit store: %s", auditBackend)
+
+	// Optionally select the review backend (dynamodb or memory)
+	if envReviewBackend := os.Getenv("REVIEW_STORE"); envReviewBackend != "" {
+		reviewBackend = envReviewBackend
+	}
+	if envReviewTableName := os.Getenv("REVIEWS_TABLE"); envReviewTableName != "" {
+		reviewTableName = envReviewTableName
+	}
+	log.Printf("Using review store: %s", reviewBackend)
+
+	// Optionally change how often the search index is rebuilt from the store (0 disables it)
+	if envIndexRefresh := os.Getenv("SEARCH_INDEX_REFRESH"); envIndexRefresh != "" {
+		interval, err := time.ParseDuration(envIndexRefresh)
+		if err != nil || interval < 0 {
+			log.Fatalf("Invalid SEARCH_INDEX_REFRESH value: %s", envIndexRefresh)
+		}
+		indexRefresh = interval
+	}
 }
 
 func initializeDynamoDB() {
@@ -60,7 +131,51 @@ func initializeDynamoDB() {
 	log.Println("Successfully initialized DynamoDB client")
 }
 
+func initializeRestaurantStore() {
+	switch storeBackend {
+	case "dynamodb":
+		restaurantStore = services.NewDynamoRestaurantStore(svc, tableName)
+	case "memory":
+		restaurantStore = services.NewMemoryRestaurantStore()
+	default:
+		log.Fatalf("Unknown RESTAURANT_STORE value: %s", storeBackend)
+	}
+}
+
+func initializeAuditStore() {
+	switch auditBackend {
+	case "dynamodb":
+		auditStore = services.NewDynamoAuditStore(svc, auditTableName)
+	case "memory":
+		auditStore = services.NewMemoryAuditStore(auditMemoryCapacity)
+	case "file":
+		fileStore, err := services.NewFileAuditStore(auditLogFile)
+		if err != nil {
+			log.Fatalf("Unable to open audit log file %s: %v", auditLogFile, err)
+		}
+		auditStore = fileStore
+	default:
+		log.Fatalf("Unknown AUDIT_STORE value: %s", auditBackend)
+	}
+}
+
+func initializeReviewStore() {
+	switch reviewBackend {
+	case "dynamodb":
+		reviewStore = services.NewDynamoReviewStore(svc, reviewTableName)
+	case "memory":
+		reviewStore = services.NewMemoryReviewStore()
+	default:
+		log.Fatalf("Unknown REVIEW_STORE value: %s", reviewBackend)
+	}
+}
+
 func populateTableIfEmpty() {
+	if storeBackend == "memory" {
+		populateMemoryStore()
+		return
+	}
+
 	// Check if the table is populated
 	populated, err := data.IsTablePopulated(context.TODO(), svc, tableName)
 	if err != nil {
@@ -68,6 +183,7 @@ func populateTableIfEmpty() {
 	}
 	if populated {
 		log.Printf("Table %s is already populated. Skipping initialization.", tableName)
+		backfillStoredItems()
 		return
 	}
 
@@ -80,7 +196,7 @@ func populateTableIfEmpty() {
 	}
 
 	// Insert restaurant data into DynamoDB table
-	err = data.InsertRestaurants(context.TODO(), svc, tableName, restaurants)
+	err = data.InsertRestaurants(context.TODO(), restaurantStore, restaurants)
 	if err != nil {
 		log.Fatalf("Failed to insert restaurants: %v", err)
 	}
@@ -88,12 +204,85 @@ func populateTableIfEmpty() {
 	log.Println("Successfully populated DynamoDB table with restaurant data")
 }
 
-func setupRoutes(client *dynamodb.Client) *gin.Engine {
+// backfillStoredItems brings restaurants stored by older versions of the
+// server up to the current item layout (see DynamoRestaurantStore.BackfillItems).
+func backfillStoredItems() {
+	dynamoStore, ok := restaurantStore.(*services.DynamoRestaurantStore)
+	if !ok {
+		return
+	}
+
+	// The seed supplies timezones and locations that older items lack
+	seed, err := data.LoadRestaurants("data/restaurants_data.json")
+	if err != nil {
+		log.Fatalf("Failed to load restaurants from JSON: %v", err)
+	}
+
+	updated, err := dynamoStore.BackfillItems(context.TODO(), seed)
+	if err != nil {
+		log.Fatalf("Failed to backfill stored restaurants: %v", err)
+	}
+	if updated > 0 {
+		log.Printf("Backfilled %d stored restaurants", updated)
+	}
+}
+
+func populateMemoryStore() {
+	restaurants, err := data.LoadRestaurants("data/restaurants_data.json")
+	if err != nil {
+		log.Fatalf("Failed to load restaurants from JSON: %v", err)
+	}
+
+	err = data.InsertRestaurants(context.TODO(), restaurantStore, restaurants)
+	if err != nil {
+		log.Fatalf("Failed to insert restaurants: %v", err)
+	}
+
+	log.Printf("Loaded %d restaurants into the in-memory store", len(restaurants))
+}
+
+func initializeSearchIndex() {
+	searchIndex = services.NewIndexedRestaurantStore(restaurantStore)
+	restaurantStore = searchIndex
+}
+
+// refreshSearchIndex builds the search index, then periodically rebuilds it so
+// that each replica picks up changes made through the others. Searches are
+// served from the restaurant store until the first build succeeds.
+func refreshSearchIndex(ctx context.Context) {
+	buildSearchIndex(ctx)
+	if indexRefresh == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(indexRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			buildSearchIndex(ctx)
+		}
+	}
+}
+
+func buildSearchIndex(ctx context.Context) {
+	start := time.Now()
+	count, err := searchIndex.Refresh(ctx)
+	if err != nil {
+		log.Printf("Failed to build search index: %v", err)
+		return
+	}
+	log.Printf("Indexed %d restaurants in %s", count, time.Since(start))
+}
+
+func setupRoutes(store services.RestaurantStore, suggester services.Suggester, auditStore services.AuditStore, reviewStore services.ReviewStore) *gin.Engine {
 	r := gin.Default()
 
 	// Add middleware
-	r.Use(gin.Logger())                // Request logging
-	r.Use(middleware.AuditLog(client)) // Audit logging for searches
+	r.Use(gin.Logger())                    // Request logging
+	r.Use(middleware.AuditLog(auditStore)) // Audit logging for searches
 
 	r.GET("/readiness", func(c *gin.Context) {
 		log.Println("Readiness check triggered")
@@ -105,63 +294,102 @@ func setupRoutes(client *dynamodb.Client) *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
-	// Public routes
-	setupPublicRoutes(r, client)
+	// Versioned API with enveloped responses and problem+json errors
+	v1 := r.Group(utils.V1, utils.UseV1())
+	setupPublicRoutes(v1, store, suggester, reviewStore)
+	setupAdminRoutes(v1, store, auditStore, reviewStore)
+
+	// Original routes, kept for existing clients
+	legacy := r.Group("", utils.Deprecated(utils.V1, legacyDeprecatedAt, legacySunset))
+	setupPublicRoutes(legacy, store, suggester, reviewStore)
+	setupAdminRoutes(legacy, store, auditStore, reviewStore)
 
-	// Admin routes
-	setupAdminRoutes(r, client)
+	r.NoRoute(utils.NoRoute)
 
 	return r
 }
 
-func setupPublicRoutes(r *gin.Engine, client *dynamodb.Client) {
+func setupPublicRoutes(r gin.IRouter, store services.RestaurantStore, suggester services.Suggester, reviewStore services.ReviewStore) {
 	r.GET("/restaurants/search", func(c *gin.Context) {
-		handlers.SearchRestaurants(c, client)
+		handlers.SearchRestaurants(c, store)
+	})
+	r.GET("/restaurants/suggest", func(c *gin.Context) {
+		handlers.SuggestRestaurants(c, suggester)
+	})
+	r.GET("/restaurants/:id", func(c *gin.Context) {
+		handlers.GetRestaurant(c, store)
+	})
+	r.GET("/restaurants/:id/reviews", func(c *gin.Context) {
+		handlers.ListReviews(c, store, reviewStore)
+	})
+	r.POST("/restaurants/:id/reviews", func(c *gin.Context) {
+		handlers.AddReview(c, store, reviewStore)
 	})
 }
 
-func setupAdminRoutes(r *gin.Engine, client *dynamodb.Client) {
+func setupAdminRoutes(r gin.IRouter, store services.RestaurantStore, auditStore services.AuditStore, reviewStore services.ReviewStore) {
 	admin := r.Group("/admin", handlers.AdminAuthMiddleware())
 	{
 		admin.GET("/validate", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"message": "Password is valid"})
+			utils.Respond(c, http.StatusOK, gin.H{"message": "Password is valid"})
 		})
 		admin.POST("/restaurants", func(c *gin.Context) {
-			handlers.AddRestaurant(c, client)
+			handlers.AddRestaurant(c, store)
 		})
 		admin.PUT("/restaurants/:id", func(c *gin.Context) {
-			handlers.EditRestaurant(c, client)
+			handlers.EditRestaurant(c, store)
 		})
 		admin.DELETE("/restaurants/:id", func(c *gin.Context) {
-			handlers.RemoveRestaurant(c, client)
+			handlers.RemoveRestaurant(c, store, reviewStore)
+		})
+		admin.PUT("/restaurants/:id/status", func(c *gin.Context) {
+			handlers.SetRestaurantStatus(c, store)
+		})
+		admin.PUT("/restaurants/:id/special-hours/:date", func(c *gin.Context) {
+			handlers.SetSpecialHours(c, store)
+		})
+		admin.DELETE("/restaurants/:id/special-hours/:date", func(c *gin.Context) {
+			handlers.RemoveSpecialHours(c, store)
+		})
+		admin.GET("/reviews", func(c *gin.Context) {
+			handlers.ListPendingReviews(c, reviewStore)
+		})
+		admin.PUT("/restaurants/:id/reviews/:review_id/status", func(c *gin.Context) {
+			handlers.SetReviewStatus(c, store, reviewStore)
+		})
+		admin.DELETE("/restaurants/:id/reviews/:review_id", func(c *gin.Context) {
+			handlers.DeleteReview(c, store, reviewStore)
+		})
+		admin.GET("/kosher-certifications/expiring", func(c *gin.Context) {
+			handlers.GetExpiringCertifications(c, store)
 		})
 		admin.GET("/logs", func(c *gin.Context) {
 			// Fetch query parameter for 'minutes'
 			minutesParam := c.DefaultQuery("minutes", "1440") // Default to 1440 minutes (24 hours)
 			minutes, err := strconv.Atoi(minutesParam)
 			if err != nil || minutes < 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minutes parameter"})
+				utils.RespondError(c, http.StatusBadRequest, "Invalid minutes parameter")
 				return
 			}
 
 			// Use the appropriate function from the services package
-			logs, err := services.GetFilteredLogs(c.Request.Context(), client, minutes)
+			logs, err := services.GetFilteredLogs(c.Request.Context(), auditStore, minutes)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch logs"})
+				utils.RespondError(c, http.StatusInternalServerError, "Failed to fetch logs")
 				return
 			}
 
-			c.JSON(http.StatusOK, logs)
+			utils.Respond(c, http.StatusOK, logs)
 		})
 		admin.GET("/restaurants/:id", func(c *gin.Context) {
-			handlers.GetRestaurantByID(c, client)
+			handlers.GetRestaurantByID(c, store)
 		})
 	}
 }
 
 func main() {
-	// Initialize Gin routes with the DynamoDB client
-	r := setupRoutes(svc)
+	// Initialize Gin routes with the restaurant and audit stores
+	r := setupRoutes(restaurantStore, searchIndex, auditStore, reviewStore)
 
 	// Static file serving
 	r.Static("/static", "./static")
@@ -179,6 +407,11 @@ func main() {
 		Handler: r,
 	}
 
+	// Keep the search index in step with the store
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go refreshSearchIndex(refreshCtx)
+
 	// Start the server in a goroutine
 	go func() {
 		log.Printf("Starting server on port %s...", port)
@@ -198,5 +431,12 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// Flush and release the audit store if it holds resources
+	if closer, ok := auditStore.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Error closing audit store: %v", err)
+		}
+	}
+
 	log.Println("Server exiting")
 }
@@ -5,14 +5,14 @@ import (
 	"strings"
 
 	"server/services"
+	"server/utils"
 
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/gin-gonic/gin"
 )
 
-func AuditLog(client *dynamodb.Client) gin.HandlerFunc {
+func AuditLog(store services.AuditStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == "/readiness" || c.Request.URL.Path == "/readyness" || strings.HasPrefix(c.Request.URL.Path, "/admin/logs") {
+		if c.Request.URL.Path == "/readiness" || c.Request.URL.Path == "/readyness" || strings.HasPrefix(strings.TrimPrefix(c.Request.URL.Path, utils.V1), "/admin/logs") {
 			c.Next()
 			return
 		}
@@ -30,7 +30,7 @@ func AuditLog(client *dynamodb.Client) gin.HandlerFunc {
 		}
 
 		// Log the audit entry
-		err = services.LogAuditEntry(c.Request.Context(), client, queryString, clientIP, country)
+		err = services.LogAuditEntry(c.Request.Context(), store, queryString, clientIP, country)
 		if err != nil {
 			log.Printf("Failed to log audit entry: %v", err)
 		}
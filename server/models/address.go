@@ -0,0 +1,66 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// statePostalPattern matches the "IL 62704" part of a US-style address: a
+// two-letter state code, a postal code, or both.
+var statePostalPattern = regexp.MustCompile(`^([A-Z]{2})?\s*(\d{5}(?:-\d{4})?)?$`)
+
+// AddressParts are the structured components of a one-line address.
+type AddressParts struct {
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+// ParseAddress splits a one-line address such as "6218 Pine St, San Diego, USA"
+// or "1 Main St, Springfield, IL 62704, USA" into its parts. The last segment
+// is the country and the one before it the city, unless that is a state and
+// postal code. Addresses with fewer segments leave the parts empty.
+func ParseAddress(address string) AddressParts {
+	var segments []string
+	for _, segment := range strings.Split(address, ",") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	var parts AddressParts
+	n := len(segments)
+	if n < 3 {
+		return parts
+	}
+
+	parts.Country = segments[n-1]
+	cityIndex := n - 2
+	if match := statePostalPattern.FindStringSubmatch(segments[n-2]); match != nil && n >= 4 {
+		parts.State = match[1]
+		parts.PostalCode = match[2]
+		cityIndex = n - 3
+	}
+	parts.City = segments[cityIndex]
+
+	return parts
+}
+
+// FillAddressParts sets the structured address fields that are empty from the
+// parsed one-line Address, leaving fields that were given explicitly alone.
+func (r *Restaurant) FillAddressParts() {
+	parts := ParseAddress(r.Address)
+	if r.City == "" {
+		r.City = parts.City
+	}
+	if r.State == "" {
+		r.State = parts.State
+	}
+	if r.PostalCode == "" {
+		r.PostalCode = parts.PostalCode
+	}
+	if r.Country == "" {
+		r.Country = parts.Country
+	}
+}
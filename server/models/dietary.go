@@ -0,0 +1,68 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// Dietary tags a restaurant can be labelled with.
+const (
+	DietKosher     = "kosher"
+	DietHalal      = "halal"
+	DietVegetarian = "vegetarian"
+	DietVegan      = "vegan"
+	DietGlutenFree = "gluten_free"
+	DietNutFree    = "nut_free"
+)
+
+// DietaryTags is the vocabulary of dietary tags, in display order.
+var DietaryTags = []string{DietKosher, DietHalal, DietVegetarian, DietVegan, DietGlutenFree, DietNutFree}
+
+// IsDietaryTag reports whether the tag is in the vocabulary, ignoring case and surrounding spaces.
+func IsDietaryTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, known := range DietaryTags {
+		if tag == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeDietaryTags lowercases, de-duplicates and sorts the dietary tags,
+// and keeps the kosher tag in step with IsKosher. IsKosher wins, so that a
+// restaurant read back with its tags can still be un-marked kosher.
+func (r *Restaurant) NormalizeDietaryTags() {
+	seen := make(map[string]bool, len(r.DietaryTags)+1)
+	var tags []string
+	for _, tag := range r.DietaryTags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] || tag == DietKosher {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if r.IsKosher {
+		tags = append(tags, DietKosher)
+	}
+
+	sort.Strings(tags)
+	r.DietaryTags = tags
+}
+
+// HasDietaryTag reports whether the restaurant has the tag. The kosher tag
+// is IsKosher, which also covers restaurants stored before tags existed.
+func (r Restaurant) HasDietaryTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == DietKosher {
+		return r.IsKosher
+	}
+	for _, t := range r.DietaryTags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package models
+
+// Kosher certificate levels.
+const (
+	KosherMeat  = "meat"
+	KosherDairy = "dairy"
+	KosherParve = "parve"
+)
+
+// KosherCertification describes the certificate backing a kosher restaurant.
+type KosherCertification struct {
+	Agency    string `json:"agency" dynamodbav:"agency"`
+	Level     string `json:"level" dynamodbav:"level"`           // meat, dairy or parve
+	ExpiresOn string `json:"expires_on" dynamodbav:"expires_on"` // YYYY-MM-DD, the last day it is valid
+}
+
+// IsValidOn reports whether the certificate is still valid on the given
+// YYYY-MM-DD date. Dates in that layout compare correctly as strings.
+func (c *KosherCertification) IsValidOn(date string) bool {
+	return c != nil && date <= c.ExpiresOn
+}
@@ -1,12 +1,73 @@
 package models
 
+import "time"
+
+// Restaurant statuses. An empty status is treated as active.
+const (
+	StatusActive            = "active"
+	StatusTemporarilyClosed = "temporarily_closed"
+	StatusPermanentlyClosed = "permanently_closed"
+)
+
 type Restaurant struct {
-	RestaurantID string            `json:"restaurant_id" dynamodbav:"restaurant_id"`
-	Name         string            `json:"restaurant_name" dynamodbav:"restaurant_name"`
-	Address      string            `json:"address" dynamodbav:"address"`
-	Phone        string            `json:"phone" dynamodbav:"phone"`
-	Website      string            `json:"website" dynamodbav:"website"`
-	CuisineType  string            `json:"cuisine_type" dynamodbav:"cuisine_type"`
-	IsKosher     bool              `json:"is_kosher" dynamodbav:"is_kosher"`
-	OpeningHours map[string]string `json:"opening_hours" dynamodbav:"opening_hours"`
+	RestaurantID        string               `json:"restaurant_id" dynamodbav:"restaurant_id"`
+	Name                string               `json:"restaurant_name" dynamodbav:"restaurant_name"`
+	Address             string               `json:"address" dynamodbav:"address"` // one line, e.g. "6218 Pine St, San Diego, USA"
+	City                string               `json:"city,omitempty" dynamodbav:"city,omitempty"`
+	State               string               `json:"state,omitempty" dynamodbav:"state,omitempty"`
+	PostalCode          string               `json:"postal_code,omitempty" dynamodbav:"postal_code,omitempty"`
+	Country             string               `json:"country,omitempty" dynamodbav:"country,omitempty"`
+	Location            *Location            `json:"location,omitempty" dynamodbav:"location,omitempty"`
+	Timezone            string               `json:"timezone" dynamodbav:"timezone"` // IANA name, e.g. America/New_York
+	Phone               string               `json:"phone" dynamodbav:"phone"`
+	Website             string               `json:"website" dynamodbav:"website"`
+	CuisineType         string               `json:"cuisine_type" dynamodbav:"cuisine_type"`
+	IsKosher            bool                 `json:"is_kosher" dynamodbav:"is_kosher"`
+	KosherCertification *KosherCertification `json:"kosher_certification,omitempty" dynamodbav:"kosher_certification,omitempty"`
+	DietaryTags         []string             `json:"dietary_tags,omitempty" dynamodbav:"dietary_tags,omitempty,stringset"` // from DietaryTags; "kosher" mirrors IsKosher
+	PriceLevel          int                  `json:"price_level,omitempty" dynamodbav:"price_level,omitempty"`             // 1 (inexpensive) to 4 (very expensive), 0 if unknown
+	Rating              float64              `json:"rating,omitempty" dynamodbav:"rating,omitempty"`                       // average rating from 1 to 5, 0 if unrated; RatingSum / RatingCount
+	RatingSum           float64              `json:"-" dynamodbav:"rating_sum"`                                            // sum of the approved review ratings
+	RatingCount         int                  `json:"rating_count,omitempty" dynamodbav:"rating_count,omitempty"`           // number of ratings summed into RatingSum
+	OpeningHours        WeeklySchedule       `json:"opening_hours" dynamodbav:"opening_hours"`
+	SpecialHours        []SpecialHours       `json:"special_hours,omitempty" dynamodbav:"special_hours,omitempty"`
+	Status              string               `json:"status,omitempty" dynamodbav:"status,omitempty"`
+	StatusReason        string               `json:"status_reason,omitempty" dynamodbav:"status_reason,omitempty"`
+	ReopenDate          string               `json:"reopen_date,omitempty" dynamodbav:"reopen_date,omitempty"` // YYYY-MM-DD, temporarily closed only
+	UpdatedAt           *time.Time           `json:"updated_at,omitempty" dynamodbav:"updated_at,omitempty"`
+}
+
+// Touch records that the restaurant was changed just now. The time is kept to
+// whole seconds, the precision of HTTP Last-Modified dates.
+func (r *Restaurant) Touch() {
+	now := time.Now().UTC().Truncate(time.Second)
+	r.UpdatedAt = &now
+}
+
+// NormalizeRating derives Rating from RatingSum and RatingCount. Restaurants
+// stored before ratings were summed have only the average, so their sum is
+// derived from it first.
+func (r *Restaurant) NormalizeRating() {
+	if r.RatingCount <= 0 {
+		r.Rating, r.RatingSum, r.RatingCount = 0, 0, 0
+		return
+	}
+	if r.RatingSum == 0 {
+		r.RatingSum = r.Rating * float64(r.RatingCount)
+	}
+	r.Rating = r.RatingSum / float64(r.RatingCount)
+}
+
+// Price level and rating bounds.
+const (
+	MinPriceLevel = 1
+	MaxPriceLevel = 4
+	MinRating     = 1
+	MaxRating     = 5
+)
+
+// Location is a geographic coordinate in decimal degrees.
+type Location struct {
+	Latitude  float64 `json:"lat" dynamodbav:"lat"`
+	Longitude float64 `json:"lng" dynamodbav:"lng"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Review length limits.
+const (
+	MaxReviewAuthorLength = 100
+	MaxReviewTextLength   = 2000
+)
+
+// Review moderation statuses. New reviews are pending until an admin approves
+// or rejects them. An empty status is treated as approved, for reviews posted
+// before moderation was introduced.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's review of a restaurant. Review IDs are time-ordered
+// (UUIDv7), so sorting them by ID sorts the reviews by when they were posted.
+type Review struct {
+	RestaurantID string     `json:"restaurant_id" dynamodbav:"restaurant_id"`
+	ReviewID     string     `json:"review_id" dynamodbav:"review_id"`
+	Rating       int        `json:"rating" dynamodbav:"rating"` // 1 to 5
+	Text         string     `json:"text" dynamodbav:"text"`
+	Author       string     `json:"author" dynamodbav:"author"` // display name
+	CreatedAt    time.Time  `json:"created_at" dynamodbav:"created_at"`
+	Status       string     `json:"status,omitempty" dynamodbav:"status,omitempty"`
+	StatusReason string     `json:"status_reason,omitempty" dynamodbav:"status_reason,omitempty"` // why the review was approved or rejected
+	ModeratedAt  *time.Time `json:"moderated_at,omitempty" dynamodbav:"moderated_at,omitempty"`
+}
+
+// IsApproved reports whether the review is shown publicly and counts toward
+// the restaurant's rating.
+func (r Review) IsApproved() bool {
+	return r.Status == "" || r.Status == ReviewApproved
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// MinutesPerDay is the length of a day in the minute-of-day scale used by TimeRange.
+	MinutesPerDay = 24 * 60
+
+	// ClosedLabel is how a day without opening intervals is written.
+	ClosedLabel = "Closed"
+)
+
+// TimeRange is an opening interval in minutes since midnight. End is always
+// after Start; an interval that runs past midnight has End beyond 24:00, so
+// "18:00-02:00" is stored as {1080, 1560}.
+type TimeRange struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the minute-of-day offset falls inside the range.
+// The end of the range is exclusive: a restaurant open 9:00-17:00 is closed at 17:00.
+func (r TimeRange) Contains(minute int) bool {
+	return minute >= r.Start && minute < r.End
+}
+
+// DaySchedule lists the opening intervals of a single day. An empty schedule means closed.
+type DaySchedule []TimeRange
+
+// WeeklySchedule is the weekly opening-hours template keyed by weekday.
+//
+// It is stored and exchanged in the same shape as before, a map from weekday
+// name to a string such as "9:00-17:00", "11:00-14:00,17:00-22:00",
+// "18:00-02:00" or "Closed".
+type WeeklySchedule map[time.Weekday]DaySchedule
+
+// ParseDaySchedule parses a day's opening hours, e.g. "11:00-14:00,17:00-22:00" or "Closed".
+func ParseDaySchedule(value string) (DaySchedule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.EqualFold(value, ClosedLabel) {
+		return DaySchedule{}, nil
+	}
+
+	var day DaySchedule
+	for _, part := range strings.Split(value, ",") {
+		times := strings.Split(strings.TrimSpace(part), "-")
+		if len(times) != 2 {
+			return nil, fmt.Errorf("invalid opening hours %q: expected HH:MM-HH:MM", part)
+		}
+
+		start, err := parseClock(times[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening hours %q: %v", part, err)
+		}
+		end, err := parseClock(times[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening hours %q: %v", part, err)
+		}
+		if start == MinutesPerDay {
+			return nil, fmt.Errorf("invalid opening hours %q: cannot open at 24:00", part)
+		}
+
+		// A closing time at or before the opening time continues into the next day
+		if end <= start {
+			end += MinutesPerDay
+		}
+		day = append(day, TimeRange{Start: start, End: end})
+	}
+
+	return day, nil
+}
+
+// parseClock parses "H:MM" or "HH:MM" into minutes since midnight. "24:00" is accepted as end of day.
+func parseClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+
+	total := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || total > MinutesPerDay {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return total, nil
+}
+
+// String formats the day schedule back to its textual form.
+func (d DaySchedule) String() string {
+	if len(d) == 0 {
+		return ClosedLabel
+	}
+
+	parts := make([]string, len(d))
+	for i, r := range d {
+		parts[i] = formatClock(r.Start) + "-" + formatClock(r.End)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatClock(minutes int) string {
+	if minutes != MinutesPerDay {
+		minutes %= MinutesPerDay
+	}
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func (d DaySchedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *DaySchedule) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	day, err := ParseDaySchedule(value)
+	if err != nil {
+		return err
+	}
+	*d = day
+	return nil
+}
+
+func (d DaySchedule) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberS{Value: d.String()}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue loads a stored day, treating malformed hours as closed.
+func (d *DaySchedule) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	str, ok := av.(*types.AttributeValueMemberS)
+	if !ok {
+		*d = DaySchedule{}
+		return nil
+	}
+
+	day, err := ParseDaySchedule(str.Value)
+	if err != nil {
+		log.Printf("Treating %q as closed: %v", str.Value, err)
+		day = DaySchedule{}
+	}
+	*d = day
+	return nil
+}
+
+// SpecialHours overrides the weekly schedule on one calendar date, for
+// holiday closures or shortened hours. Hours of "Closed" closes the
+// restaurant for the whole day.
+type SpecialHours struct {
+	Date  string      `json:"date" dynamodbav:"date"` // YYYY-MM-DD in the restaurant's timezone
+	Hours DaySchedule `json:"hours" dynamodbav:"hours"`
+	Note  string      `json:"note,omitempty" dynamodbav:"note,omitempty"`
+}
+
+// DateLayout is the format of SpecialHours dates.
+const DateLayout = "2006-01-02"
+
+// ParseWeeklySchedule parses a map of weekday names to opening hours.
+func ParseWeeklySchedule(days map[string]string) (WeeklySchedule, error) {
+	schedule := make(WeeklySchedule, len(days))
+	for name, value := range days {
+		weekday, err := parseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		day, err := ParseDaySchedule(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		schedule[weekday] = day
+	}
+	return schedule, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+// Strings returns the schedule as a map of weekday names to opening hours.
+func (s WeeklySchedule) Strings() map[string]string {
+	days := make(map[string]string, len(s))
+	for weekday, day := range s {
+		days[weekday.String()] = day.String()
+	}
+	return days
+}
+
+func (s WeeklySchedule) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.Strings())
+}
+
+func (s *WeeklySchedule) UnmarshalJSON(data []byte) error {
+	var days map[string]string
+	if err := json.Unmarshal(data, &days); err != nil {
+		return err
+	}
+	if days == nil {
+		*s = nil
+		return nil
+	}
+
+	schedule, err := ParseWeeklySchedule(days)
+	if err != nil {
+		return err
+	}
+	*s = schedule
+	return nil
+}
+
+func (s WeeklySchedule) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	if s == nil {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+
+	item := make(map[string]types.AttributeValue, len(s))
+	for name, value := range s.Strings() {
+		item[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return &types.AttributeValueMemberM{Value: item}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue loads a stored schedule. Unlike the JSON
+// decoder it does not fail on malformed days, so one bad item cannot break
+// every search; such days are logged and treated as closed.
+func (s *WeeklySchedule) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	item, ok := av.(*types.AttributeValueMemberM)
+	if !ok {
+		*s = nil
+		return nil
+	}
+
+	schedule := make(WeeklySchedule, len(item.Value))
+	for name, value := range item.Value {
+		str, ok := value.(*types.AttributeValueMemberS)
+		if !ok || strings.TrimSpace(str.Value) == "" {
+			continue
+		}
+
+		weekday, err := parseWeekday(name)
+		if err != nil {
+			log.Printf("Ignoring opening hours for %q: %v", name, err)
+			continue
+		}
+		day, err := ParseDaySchedule(str.Value)
+		if err != nil {
+			log.Printf("Treating %s as closed: %v", name, err)
+			day = DaySchedule{}
+		}
+		schedule[weekday] = day
+	}
+
+	*s = schedule
+	return nil
+}
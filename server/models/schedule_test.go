@@ -0,0 +1,58 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDaySchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    DaySchedule
+		text    string // String() of the parsed schedule
+		wantErr bool
+	}{
+		{name: "single range", value: "9:00-17:00", want: DaySchedule{{540, 1020}}, text: "09:00-17:00"},
+		{name: "split range", value: "11:00-14:00,17:00-22:00", want: DaySchedule{{660, 840}, {1020, 1320}}, text: "11:00-14:00,17:00-22:00"},
+		{name: "split range with spaces", value: " 11:00-14:00 , 17:00-22:00 ", want: DaySchedule{{660, 840}, {1020, 1320}}, text: "11:00-14:00,17:00-22:00"},
+		{name: "overnight", value: "18:00-02:00", want: DaySchedule{{1080, 1560}}, text: "18:00-02:00"},
+		{name: "until midnight", value: "22:00-24:00", want: DaySchedule{{1320, 1440}}, text: "22:00-24:00"},
+		{name: "closing at midnight", value: "22:00-00:00", want: DaySchedule{{1320, 1440}}, text: "22:00-24:00"},
+		{name: "whole day", value: "00:00-24:00", want: DaySchedule{{0, 1440}}, text: "00:00-24:00"},
+		{name: "split with overnight", value: "11:00-15:00,19:00-03:00", want: DaySchedule{{660, 900}, {1140, 1620}}, text: "11:00-15:00,19:00-03:00"},
+		{name: "same opening and closing time", value: "09:00-09:00", want: DaySchedule{{540, 1980}}, text: "09:00-09:00"},
+		{name: "closed", value: "Closed", want: DaySchedule{}, text: ClosedLabel},
+		{name: "closed lowercase", value: "closed", want: DaySchedule{}, text: ClosedLabel},
+		{name: "empty", value: "", want: DaySchedule{}, text: ClosedLabel},
+		{name: "hours only", value: "9-17", wantErr: true},
+		{name: "single time", value: "09:00", wantErr: true},
+		{name: "opening at 24:00", value: "24:00-02:00", wantErr: true},
+		{name: "hour out of range", value: "25:00-26:00", wantErr: true},
+		{name: "minute out of range", value: "09:60-10:00", wantErr: true},
+		{name: "one-digit minute", value: "9:5-10:00", wantErr: true},
+		{name: "empty part", value: "11:00-14:00,", wantErr: true},
+		{name: "text", value: "noon-night", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDaySchedule(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseDaySchedule(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDaySchedule(%q) returned error: %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDaySchedule(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+			if text := got.String(); text != tt.text {
+				t.Errorf("ParseDaySchedule(%q).String() = %q, want %q", tt.value, text, tt.text)
+			}
+		})
+	}
+}
@@ -6,13 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 type GeoResponse struct {
@@ -52,25 +46,16 @@ func GetCountryFromIP(ip string) (string, error) {
 	return geoData.Country, nil
 }
 
-// LogAuditEntry logs an audit entry into the audit_logs table.
-func LogAuditEntry(ctx context.Context, client *dynamodb.Client, query, clientIP, country string) error {
-	now := time.Now().UTC()
-	entry := map[string]types.AttributeValue{
-		"timestamp": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
-		"query":     &types.AttributeValueMemberS{Value: query},
-		"ip":        &types.AttributeValueMemberS{Value: clientIP},
-		"country":   &types.AttributeValueMemberS{Value: country},
-	}
-
-	tableName := os.Getenv("AUDIT_LOGS_TABLE")
-	if tableName == "" {
-		tableName = "audit_logs" // Default table name
+// LogAuditEntry logs an audit entry into the audit store.
+func LogAuditEntry(ctx context.Context, store AuditStore, query, clientIP, country string) error {
+	entry := AuditEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Query:     query,
+		IP:        clientIP,
+		Country:   country,
 	}
 
-	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item:      entry,
-	})
+	err := store.PutAuditEntry(ctx, entry)
 	if err != nil {
 		log.Printf("Error logging audit entry: %v", err)
 		return err
@@ -81,46 +66,26 @@ func LogAuditEntry(ctx context.Context, client *dynamodb.Client, query, clientIP
 }
 
 // GetFilteredLogs fetches logs from the last 'minutes' specified, defaulting to 24 hours if 'minutes' is 0.
-func GetFilteredLogs(ctx context.Context, client *dynamodb.Client, minutes int) ([]map[string]interface{}, error) {
-	var filterTime string
+func GetFilteredLogs(ctx context.Context, store AuditStore, minutes int) ([]AuditEntry, error) {
+	var since time.Time
 	if minutes > 0 {
-		filterTime = time.Now().Add(-time.Duration(minutes) * time.Minute).Format(time.RFC3339)
+		since = time.Now().Add(-time.Duration(minutes) * time.Minute)
 	} else {
 		// Default to the last 24 hours
-		filterTime = time.Now().Add(-24 * time.Hour).Format(time.RFC3339)
+		since = time.Now().Add(-24 * time.Hour)
 	}
 
-	tableName := os.Getenv("AUDIT_LOGS_TABLE")
-	if tableName == "" {
-		tableName = "audit_logs" // Default table name
-	}
+	log.Printf("Fetching audit logs starting from: %s", since.UTC().Format(time.RFC3339))
 
-	log.Printf("Fetching audit logs starting from: %s in table: %s", filterTime, tableName)
-
-	input := &dynamodb.ScanInput{
-		TableName:        aws.String(tableName),
-		FilterExpression: aws.String("#ts >= :filterTime"),
-		ExpressionAttributeNames: map[string]string{
-			"#ts": "timestamp",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":filterTime": &types.AttributeValueMemberS{Value: filterTime},
-		},
-	}
-
-	result, err := client.Scan(ctx, input)
+	logs, err := store.ListAuditEntries(ctx, since)
 	if err != nil {
 		log.Printf("Error fetching filtered logs: %v", err)
 		return nil, err
 	}
-
-	var logs []map[string]interface{}
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &logs)
-	if err != nil {
-		log.Printf("Error unmarshalling filtered logs: %v", err)
-		return nil, err
+	if logs == nil {
+		logs = []AuditEntry{}
 	}
 
-	log.Printf("Successfully fetched %d audit logs starting from %s", len(logs), filterTime)
+	log.Printf("Successfully fetched %d audit logs", len(logs))
 	return logs, nil
 }
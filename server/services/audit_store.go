@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// AuditEntry is a single audited request.
+type AuditEntry struct {
+	Timestamp string `json:"timestamp" dynamodbav:"timestamp"`
+	Query     string `json:"query" dynamodbav:"query"`
+	IP        string `json:"ip" dynamodbav:"ip"`
+	Country   string `json:"country" dynamodbav:"country"`
+}
+
+// AuditStore is the persistence layer used for audit logs.
+type AuditStore interface {
+	PutAuditEntry(ctx context.Context, entry AuditEntry) error
+	ListAuditEntries(ctx context.Context, since time.Time) ([]AuditEntry, error)
+}
+
+// entryIsSince reports whether the entry was logged at or after since.
+// Entries with an unparsable timestamp are kept rather than silently dropped.
+func entryIsSince(entry AuditEntry, since time.Time) bool {
+	ts, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return true
+	}
+	return !ts.Before(since.Truncate(time.Second))
+}
+
+// DynamoAuditStore keeps audit entries in a DynamoDB table.
+type DynamoAuditStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoAuditStore(client *dynamodb.Client, tableName string) *DynamoAuditStore {
+	return &DynamoAuditStore{client: client, tableName: tableName}
+}
+
+func (s *DynamoAuditStore) PutAuditEntry(ctx context.Context, entry AuditEntry) error {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+func (s *DynamoAuditStore) ListAuditEntries(ctx context.Context, since time.Time) ([]AuditEntry, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(s.tableName),
+		FilterExpression: aws.String("#ts >= :filterTime"),
+		ExpressionAttributeNames: map[string]string{
+			"#ts": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":filterTime": &types.AttributeValueMemberS{Value: since.UTC().Format(time.RFC3339)},
+		},
+	}
+
+	result, err := s.client.Scan(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	err = attributevalue.UnmarshalListOfMaps(result.Items, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// MemoryAuditStore keeps the most recent audit entries in a fixed-size ring
+// buffer. Older entries are overwritten once the buffer is full.
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+	next    int
+	full    bool
+}
+
+func NewMemoryAuditStore(capacity int) *MemoryAuditStore {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &MemoryAuditStore{entries: make([]AuditEntry, capacity)}
+}
+
+func (s *MemoryAuditStore) PutAuditEntry(ctx context.Context, entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % len(s.entries)
+	if s.next == 0 {
+		s.full = true
+	}
+	return nil
+}
+
+func (s *MemoryAuditStore) ListAuditEntries(ctx context.Context, since time.Time) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Walk the buffer from the oldest entry to the newest
+	ordered := s.entries[:s.next]
+	if s.full {
+		ordered = append(append([]AuditEntry{}, s.entries[s.next:]...), s.entries[:s.next]...)
+	}
+
+	var entries []AuditEntry
+	for _, entry := range ordered {
+		if entryIsSince(entry, since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// FileAuditStore appends audit entries to a JSON-lines file, one entry per line.
+type FileAuditStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileAuditStore(path string) (*FileAuditStore, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditStore{path: path, file: file}, nil
+}
+
+func (s *FileAuditStore) PutAuditEntry(ctx context.Context, entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileAuditStore) ListAuditEntries(ctx context.Context, since time.Time) ([]AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// A decoder rather than a line scanner, since queries make lines of any length
+	var entries []AuditEntry
+	decoder := json.NewDecoder(file)
+	for {
+		var entry AuditEntry
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entryIsSince(entry, since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// Close releases the underlying file handle.
+func (s *FileAuditStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileAuditStoreLongEntries(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileAuditStore(filepath.Join(t.TempDir(), "audit_logs.jsonl"))
+	if err != nil {
+		t.Fatalf("NewFileAuditStore: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	queries := []string{"cuisine=italian", "q=" + strings.Repeat("x", 1<<20), "is_kosher=true"}
+	for _, query := range queries {
+		if err := store.PutAuditEntry(ctx, AuditEntry{Timestamp: now, Query: query}); err != nil {
+			t.Fatalf("PutAuditEntry: %v", err)
+		}
+	}
+
+	entries, err := store.ListAuditEntries(ctx, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(entries) != len(queries) {
+		t.Fatalf("ListAuditEntries returned %d entries, want %d", len(entries), len(queries))
+	}
+	for i, entry := range entries {
+		if entry.Query != queries[i] {
+			t.Errorf("entry %d has a query of %d bytes, want %d", i, len(entry.Query), len(queries[i]))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"strconv"
+	"time"
+)
+
+// Facets counts the restaurants matching a search, across all pages, by
+// cuisine, kosher (with a valid certificate), city and whether they are open
+// now. Kosher and open-now counts are keyed by "true" and "false", the values
+// the search accepts.
+type Facets struct {
+	Cuisine map[string]int `json:"cuisine"`
+	Kosher  map[string]int `json:"kosher"`
+	City    map[string]int `json:"city"`
+	OpenNow map[string]int `json:"open_now"`
+}
+
+// countFacets computes the facets of the filtered search results. Cuisines
+// and cities are grouped case-insensitively under the first spelling seen.
+func countFacets(results []RestaurantResult, now time.Time) Facets {
+	facets := Facets{
+		Cuisine: make(map[string]int),
+		Kosher:  make(map[string]int),
+		City:    make(map[string]int),
+		OpenNow: make(map[string]int),
+	}
+
+	cuisineLabels := make(map[string]string)
+	cityLabels := make(map[string]string)
+	for _, result := range results {
+		if key := cuisineKey(result.CuisineType); key != "" {
+			facets.Cuisine[facetLabel(cuisineLabels, key, result.CuisineType)]++
+		}
+		if city := restaurantCity(result.Restaurant); city != "" {
+			facets.City[facetLabel(cityLabels, placeKey(city), city)]++
+		}
+		facets.Kosher[strconv.FormatBool(isCertifiedKosher(result.Restaurant, now))]++
+		facets.OpenNow[strconv.FormatBool(result.IsOpenNow)]++
+	}
+
+	return facets
+}
+
+// facetLabel returns the label recorded for the key, recording value if it is the first.
+func facetLabel(labels map[string]string, key, value string) string {
+	label, ok := labels[key]
+	if !ok {
+		label = value
+		labels[key] = label
+	}
+	return label
+}
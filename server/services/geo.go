@@ -0,0 +1,22 @@
+package services
+
+import (
+	"math"
+
+	"server/models"
+)
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm returns the great-circle distance between two locations using the haversine formula.
+func DistanceKm(a, b models.Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"server/models"
+)
+
+// openInterval is an opening interval expressed in minutes from the midnight
+// that starts the reference day. Negative offsets fall on the previous day.
+type openInterval struct {
+	start int
+	end   int
+}
+
+// scheduleHorizon is the end of the window covered by scheduleIntervals. An
+// interval reaching it may continue further, so its end is not a real closing time.
+const scheduleHorizon = 8 * models.MinutesPerDay
+
+// isOperatingOn reports whether the restaurant's status lets it open on the
+// given date. A temporary closure ends on its reopen date, if one is set.
+func isOperatingOn(r models.Restaurant, date time.Time) bool {
+	switch r.Status {
+	case "", models.StatusActive:
+		return true
+	case models.StatusTemporarilyClosed:
+		return r.ReopenDate != "" && date.Format(models.DateLayout) >= r.ReopenDate
+	default:
+		return false
+	}
+}
+
+// isRestaurantActive reports whether the restaurant is operating at the given
+// instant, judged by the date in the restaurant's own timezone.
+func isRestaurantActive(r models.Restaurant, at time.Time) bool {
+	return isOperatingOn(r, restaurantTime(r, at))
+}
+
+// hoursOn returns the opening intervals that start on the given date,
+// taking special hours for that date over the weekly schedule. Restaurants
+// that are closed by their status have no hours.
+func hoursOn(r models.Restaurant, date time.Time) models.DaySchedule {
+	if !isOperatingOn(r, date) {
+		return nil
+	}
+
+	day := date.Format(models.DateLayout)
+	for _, special := range r.SpecialHours {
+		if special.Date == day {
+			return special.Hours
+		}
+	}
+	return r.OpeningHours[date.Weekday()]
+}
+
+// scheduleIntervals expands the restaurant's schedule around the reference
+// day into concrete intervals, from the day before it through one week after
+// it. Intervals that touch or overlap are merged, so a restaurant open
+// "18:00-24:00" on Monday and "00:00-02:00" on Tuesday is a single interval.
+func scheduleIntervals(r models.Restaurant, ref time.Time) []openInterval {
+	var intervals []openInterval
+	for day := -1; day <= 7; day++ {
+		date := time.Date(ref.Year(), ref.Month(), ref.Day()+day, 0, 0, 0, 0, ref.Location())
+		for _, hours := range hoursOn(r, date) {
+			offset := day * models.MinutesPerDay
+			intervals = append(intervals, openInterval{start: offset + hours.Start, end: offset + hours.End})
+		}
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start < intervals[j].start
+	})
+
+	var merged []openInterval
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && interval.start <= merged[last].end {
+			if interval.end > merged[last].end {
+				merged[last].end = interval.end
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// minuteOfDay returns the number of minutes since local midnight.
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// timeAtMinute returns the wall-clock time that is the given number of minutes
+// after the midnight starting ref's day, in ref's location.
+func timeAtMinute(ref time.Time, minute int) time.Time {
+	return time.Date(ref.Year(), ref.Month(), ref.Day(), 0, minute, 0, 0, ref.Location())
+}
+
+// openUntil reports whether the restaurant is open at the given instant and,
+// if so, when that opening stretch ends in the restaurant's own timezone.
+func openUntil(r models.Restaurant, at time.Time) (time.Time, bool) {
+	local := restaurantTime(r, at)
+	minute := minuteOfDay(local)
+
+	for _, interval := range scheduleIntervals(r, local) {
+		if minute >= interval.start && minute < interval.end {
+			return timeAtMinute(local, interval.end), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextOpening returns when the restaurant next opens after the given instant,
+// in the restaurant's own timezone, looking at most a week ahead.
+func nextOpening(r models.Restaurant, at time.Time) (time.Time, bool) {
+	local := restaurantTime(r, at)
+	minute := minuteOfDay(local)
+
+	for _, interval := range scheduleIntervals(r, local) {
+		if interval.start > minute {
+			return timeAtMinute(local, interval.start), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// openingStatus computes whether the restaurant is open at the given instant,
+// when it closes if it is, and when it next opens otherwise. A restaurant
+// that never closes within the coming week has no closing time.
+func openingStatus(r models.Restaurant, at time.Time) (open bool, closesAt *time.Time, opensAt *time.Time) {
+	local := restaurantTime(r, at)
+	minute := minuteOfDay(local)
+
+	for _, interval := range scheduleIntervals(r, local) {
+		if minute >= interval.start && minute < interval.end {
+			if interval.end < scheduleHorizon {
+				closing := timeAtMinute(local, interval.end)
+				closesAt = &closing
+			}
+			return true, closesAt, nil
+		}
+		if interval.start > minute {
+			opening := timeAtMinute(local, interval.start)
+			return false, nil, &opening
+		}
+	}
+	return false, nil, nil
+}
+
+// isRestaurantOpenFor reports whether the restaurant is open at the given
+// instant and stays open for at least the given duration.
+func isRestaurantOpenFor(r models.Restaurant, at time.Time, duration time.Duration) bool {
+	until, open := openUntil(r, at)
+	if !open {
+		return false
+	}
+	return !until.Before(at.Add(duration))
+}
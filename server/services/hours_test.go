@@ -0,0 +1,203 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"server/models"
+)
+
+func mustParseDay(t *testing.T, value string) models.DaySchedule {
+	t.Helper()
+	day, err := models.ParseDaySchedule(value)
+	if err != nil {
+		t.Fatalf("ParseDaySchedule(%q): %v", value, err)
+	}
+	return day
+}
+
+// testRestaurant returns a restaurant with the given weekly hours, keyed by
+// weekday, and special hours keyed by date.
+func testRestaurant(t *testing.T, timezone string, weekly map[time.Weekday]string, special map[string]string) models.Restaurant {
+	t.Helper()
+	r := models.Restaurant{RestaurantID: "1", Timezone: timezone, OpeningHours: models.WeeklySchedule{}}
+	for weekday, hours := range weekly {
+		r.OpeningHours[weekday] = mustParseDay(t, hours)
+	}
+	for date, hours := range special {
+		r.SpecialHours = append(r.SpecialHours, models.SpecialHours{Date: date, Hours: mustParseDay(t, hours)})
+	}
+	return r
+}
+
+// 2026-03-01 is a Sunday.
+func at(day, hour, minute int, loc *time.Location) time.Time {
+	return time.Date(2026, time.March, day, hour, minute, 0, 0, loc)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestOpeningStatus(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	weekly := map[time.Weekday]string{
+		time.Sunday:    "22:00-24:00",
+		time.Monday:    "00:00-03:00,18:00-02:00",
+		time.Tuesday:   "Closed",
+		time.Wednesday: "11:00-14:00,17:00-22:00",
+	}
+	always := map[time.Weekday]string{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		always[day] = "00:00-24:00"
+	}
+
+	tests := []struct {
+		name       string
+		restaurant models.Restaurant
+		at         time.Time
+		wantOpen   bool
+		wantCloses *time.Time
+		wantOpens  *time.Time
+	}{
+		{
+			name:       "open before midnight, running into the next day",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(1, 23, 0, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(2, 3, 0, time.UTC)),
+		},
+		{
+			name:       "open after midnight, continuing the previous day",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(2, 1, 0, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(2, 3, 0, time.UTC)),
+		},
+		{
+			name:       "closed between ranges",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(2, 12, 0, time.UTC),
+			wantOpens:  timePtr(at(2, 18, 0, time.UTC)),
+		},
+		{
+			name:       "overnight range on a closed day",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(3, 1, 30, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(3, 2, 0, time.UTC)),
+		},
+		{
+			name:       "closing time is exclusive",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(3, 2, 0, time.UTC),
+			wantOpens:  timePtr(at(4, 11, 0, time.UTC)),
+		},
+		{
+			name:       "split range break",
+			restaurant: testRestaurant(t, "UTC", weekly, nil),
+			at:         at(4, 15, 0, time.UTC),
+			wantOpens:  timePtr(at(4, 17, 0, time.UTC)),
+		},
+		{
+			name:       "special hours replace the weekly hours",
+			restaurant: testRestaurant(t, "UTC", weekly, map[string]string{"2026-03-04": "09:00-10:00"}),
+			at:         at(4, 9, 30, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(4, 10, 0, time.UTC)),
+		},
+		{
+			name:       "special closure",
+			restaurant: testRestaurant(t, "UTC", weekly, map[string]string{"2026-03-04": "Closed"}),
+			at:         at(4, 12, 0, time.UTC),
+			wantOpens:  timePtr(at(8, 22, 0, time.UTC)),
+		},
+		{
+			name:       "overnight special hours",
+			restaurant: testRestaurant(t, "UTC", weekly, map[string]string{"2026-03-03": "23:00-01:00"}),
+			at:         at(4, 0, 30, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(4, 1, 0, time.UTC)),
+		},
+		{
+			name:       "hours in the restaurant's timezone",
+			restaurant: testRestaurant(t, "America/New_York", weekly, nil),
+			at:         at(3, 3, 0, time.UTC),
+			wantOpen:   true,
+			wantCloses: timePtr(at(3, 2, 0, newYork)),
+		},
+		{
+			name:       "never closing",
+			restaurant: testRestaurant(t, "UTC", always, nil),
+			at:         at(4, 12, 0, time.UTC),
+			wantOpen:   true,
+		},
+		{
+			name:       "never opening",
+			restaurant: testRestaurant(t, "UTC", nil, nil),
+			at:         at(4, 12, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, closesAt, opensAt := openingStatus(tt.restaurant, tt.at)
+			if open != tt.wantOpen {
+				t.Errorf("open = %v, want %v", open, tt.wantOpen)
+			}
+			if !sameTime(closesAt, tt.wantCloses) {
+				t.Errorf("closesAt = %v, want %v", closesAt, tt.wantCloses)
+			}
+			if !sameTime(opensAt, tt.wantOpens) {
+				t.Errorf("opensAt = %v, want %v", opensAt, tt.wantOpens)
+			}
+		})
+	}
+}
+
+func TestIsRestaurantOpenFor(t *testing.T) {
+	weekly := map[time.Weekday]string{
+		time.Sunday:  "22:00-24:00",
+		time.Monday:  "00:00-03:00,18:00-02:00",
+		time.Tuesday: "Closed",
+	}
+
+	tests := []struct {
+		name     string
+		special  map[string]string
+		at       time.Time
+		duration time.Duration
+		want     bool
+	}{
+		{name: "open now", at: at(2, 19, 0, time.UTC), want: true},
+		{name: "open past midnight", at: at(2, 23, 0, time.UTC), duration: 2 * time.Hour, want: true},
+		{name: "open until exactly closing", at: at(2, 23, 0, time.UTC), duration: 3 * time.Hour, want: true},
+		{name: "closing too soon", at: at(2, 23, 0, time.UTC), duration: 3*time.Hour + time.Minute, want: false},
+		{name: "ranges merged across midnight", at: at(1, 23, 0, time.UTC), duration: 4 * time.Hour, want: true},
+		{name: "merged ranges closing too soon", at: at(1, 23, 0, time.UTC), duration: 4*time.Hour + time.Minute, want: false},
+		{name: "closed", at: at(2, 12, 0, time.UTC), want: false},
+		{name: "special hours extend the night", special: map[string]string{"2026-03-02": "18:00-04:00"}, at: at(2, 23, 0, time.UTC), duration: 5 * time.Hour, want: true},
+		{name: "special closure", special: map[string]string{"2026-03-02": "Closed"}, at: at(2, 19, 0, time.UTC), want: false},
+		{name: "special closure keeps the previous night", special: map[string]string{"2026-03-02": "Closed"}, at: at(1, 23, 0, time.UTC), duration: time.Hour, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRestaurant(t, "UTC", weekly, tt.special)
+			if got := isRestaurantOpenFor(r, tt.at, tt.duration); got != tt.want {
+				t.Errorf("isRestaurantOpenFor(%v, %v) = %v, want %v", tt.at, tt.duration, got, tt.want)
+			}
+		})
+	}
+}
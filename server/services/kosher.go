@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"server/models"
+)
+
+// isCertifiedKosher reports whether the restaurant is kosher with a
+// certificate that has not expired on the restaurant's local date.
+func isCertifiedKosher(r models.Restaurant, now time.Time) bool {
+	if !r.IsKosher {
+		return false
+	}
+	today := restaurantTime(r, now).Format(models.DateLayout)
+	return r.KosherCertification.IsValidOn(today)
+}
+
+// CertificationExpiry is a kosher certificate that is about to expire.
+type CertificationExpiry struct {
+	RestaurantID   string `json:"restaurant_id"`
+	RestaurantName string `json:"restaurant_name"`
+	models.KosherCertification
+	DaysLeft int `json:"days_left"`
+}
+
+// ExpiringCertifications lists the kosher certificates that are still valid
+// but expire within the given number of days, soonest first. Days are
+// counted in each restaurant's own timezone.
+func ExpiringCertifications(ctx context.Context, store RestaurantStore, days int, now time.Time) ([]CertificationExpiry, error) {
+	restaurants, err := store.ListRestaurants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expiring := []CertificationExpiry{}
+	for _, r := range restaurants {
+		cert := r.KosherCertification
+		if cert == nil {
+			continue
+		}
+		expiresOn, err := time.Parse(models.DateLayout, cert.ExpiresOn)
+		if err != nil {
+			continue
+		}
+
+		local := restaurantTime(r, now)
+		today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		daysLeft := int(expiresOn.Sub(today).Hours() / 24)
+		if daysLeft < 0 || daysLeft > days {
+			continue
+		}
+
+		expiring = append(expiring, CertificationExpiry{
+			RestaurantID:        r.RestaurantID,
+			RestaurantName:      r.Name,
+			KosherCertification: *cert,
+			DaysLeft:            daysLeft,
+		})
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		if expiring[i].ExpiresOn != expiring[j].ExpiresOn {
+			return expiring[i].ExpiresOn < expiring[j].ExpiresOn
+		}
+		return expiring[i].RestaurantID < expiring[j].RestaurantID
+	})
+
+	return expiring, nil
+}
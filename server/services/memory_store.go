@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"server/models"
+)
+
+// MemoryRestaurantStore keeps restaurants in process memory. It is meant for
+// tests and local development without AWS access.
+type MemoryRestaurantStore struct {
+	mu          sync.RWMutex
+	restaurants map[string]models.Restaurant
+}
+
+func NewMemoryRestaurantStore(restaurants ...models.Restaurant) *MemoryRestaurantStore {
+	s := &MemoryRestaurantStore{restaurants: make(map[string]models.Restaurant)}
+	for _, r := range restaurants {
+		r.NormalizeRating()
+		s.restaurants[r.RestaurantID] = r
+	}
+	return s
+}
+
+func (s *MemoryRestaurantStore) ListRestaurants(ctx context.Context) ([]models.Restaurant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restaurants := make([]models.Restaurant, 0, len(s.restaurants))
+	for _, r := range s.restaurants {
+		restaurants = append(restaurants, r)
+	}
+
+	// Keep the order stable between calls
+	sort.Slice(restaurants, func(i, j int) bool {
+		return restaurants[i].RestaurantID < restaurants[j].RestaurantID
+	})
+
+	return restaurants, nil
+}
+
+func (s *MemoryRestaurantStore) QueryRestaurants(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error) {
+	restaurants, err := s.ListRestaurants(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []models.Restaurant
+	for _, r := range restaurants {
+		if len(query.Cuisines) > 0 && !matchesCuisine(r, query.Cuisines) {
+			continue
+		}
+		if query.IsKosher != nil && r.IsKosher != *query.IsKosher {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	return matched, nil
+}
+
+func (s *MemoryRestaurantStore) GetRestaurant(ctx context.Context, restaurantID string) (*models.Restaurant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	restaurant, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrRestaurantNotFound
+	}
+	return &restaurant, nil
+}
+
+func (s *MemoryRestaurantStore) PutRestaurant(ctx context.Context, restaurant models.Restaurant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restaurant.NormalizeRating()
+	stored, ok := s.restaurants[restaurant.RestaurantID]
+	if ok && (stored.RatingSum != restaurant.RatingSum || stored.RatingCount != restaurant.RatingCount) {
+		return ErrRatingChanged
+	}
+	s.restaurants[restaurant.RestaurantID] = restaurant
+	return nil
+}
+
+func (s *MemoryRestaurantStore) DeleteRestaurant(ctx context.Context, restaurantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.restaurants, restaurantID)
+	return nil
+}
+
+func (s *MemoryRestaurantStore) AdjustRating(ctx context.Context, restaurantID string, sum, count int) (*models.Restaurant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restaurant, ok := s.restaurants[restaurantID]
+	if !ok {
+		return nil, ErrRestaurantNotFound
+	}
+	restaurant.RatingSum += float64(sum)
+	restaurant.RatingCount += count
+	restaurant.NormalizeRating()
+	restaurant.Touch()
+
+	s.restaurants[restaurantID] = restaurant
+	return &restaurant, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Sort fields accepted by search.
+const (
+	SortName     = "name"
+	SortCuisine  = "cuisine"
+	SortDistance = "distance"
+	SortClosing  = "closing" // closing soonest first; restaurants that are not open sort last
+	SortPrice    = "price"   // cheapest first; restaurants without a price level sort last
+	SortRating   = "rating"  // lowest first, so pass order=desc for the best rated; unrated restaurants sort last
+
+	// SortRelevance orders free-text matches by relevance, most relevant first in ascending order.
+	SortRelevance = "relevance"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// PageRequest selects one page of search results. Cursor is the NextCursor
+// of the previous page, or empty for the first page. Sort is one of the Sort
+// fields; when empty, results are ordered by relevance for free-text
+// searches, by distance for location searches and by restaurant ID otherwise.
+// All returns every result on a single page instead, for clients that do not
+// paginate.
+type PageRequest struct {
+	Limit      int
+	Cursor     string
+	Sort       string
+	Descending bool
+	All        bool
+}
+
+// SearchPage is one page of search results, with facet counts over all pages.
+type SearchPage struct {
+	Results    []RestaurantResult `json:"results"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	Facets     Facets             `json:"facets"`
+}
+
+// sortKey is the value a result is ordered by. Missing values, such as the
+// closing time of a restaurant that is not open, always sort last.
+type sortKey struct {
+	Text    string  `json:"t,omitempty"`
+	Number  float64 `json:"n,omitempty"`
+	Missing bool    `json:"m,omitempty"`
+}
+
+// searchCursor records the sort key of the last result on a page. Results
+// are always fully ordered, ending with the restaurant ID as a tie-breaker,
+// so the next page resumes right after that key. This keeps pages stable
+// regardless of the order DynamoDB returns items in or how its
+// LastEvaluatedKey pages fall, and even if restaurants are added or removed
+// between requests.
+type searchCursor struct {
+	Sort       string  `json:"s,omitempty"`
+	Descending bool    `json:"o,omitempty"`
+	Key        sortKey `json:"k"`
+	ID         string  `json:"id"`
+}
+
+func encodeCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (searchCursor, error) {
+	var cursor searchCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// resultSorter orders results by one sort field.
+type resultSorter struct {
+	field      string
+	descending bool
+}
+
+// newResultSorter validates the requested order against the filters, which
+// decide whether results carry a relevance or a distance to sort by.
+func newResultSorter(page PageRequest, filters SearchFilters) (resultSorter, error) {
+	byRelevance := len(tokenize(filters.Query)) > 0
+	byDistance := filters.Near != nil
+
+	field := page.Sort
+	if field == "" {
+		switch {
+		case byRelevance:
+			field = SortRelevance
+		case byDistance:
+			field = SortDistance
+		}
+	}
+
+	switch field {
+	case "", SortName, SortCuisine, SortClosing, SortPrice, SortRating:
+	case SortDistance:
+		if !byDistance {
+			return resultSorter{}, ErrInvalidSort
+		}
+	case SortRelevance:
+		if !byRelevance {
+			return resultSorter{}, ErrInvalidSort
+		}
+	default:
+		return resultSorter{}, ErrInvalidSort
+	}
+
+	return resultSorter{field: field, descending: page.Descending}, nil
+}
+
+func (s resultSorter) key(r RestaurantResult) sortKey {
+	switch s.field {
+	case SortName:
+		return sortKey{Text: strings.ToLower(r.Name)}
+	case SortCuisine:
+		return sortKey{Text: strings.ToLower(r.CuisineType)}
+	case SortDistance:
+		if r.DistanceKm == nil {
+			return sortKey{Missing: true}
+		}
+		return sortKey{Number: *r.DistanceKm}
+	case SortRelevance:
+		if r.Relevance == nil {
+			return sortKey{Missing: true}
+		}
+		// Negated so that the most relevant results come first in ascending order
+		return sortKey{Number: -*r.Relevance}
+	case SortClosing:
+		if r.ClosesAt == nil {
+			return sortKey{Missing: true}
+		}
+		return sortKey{Number: float64(r.ClosesAt.Unix())}
+	case SortPrice:
+		if r.PriceLevel == 0 {
+			return sortKey{Missing: true}
+		}
+		return sortKey{Number: float64(r.PriceLevel)}
+	case SortRating:
+		if r.Rating == 0 {
+			return sortKey{Missing: true}
+		}
+		return sortKey{Number: r.Rating}
+	}
+	return sortKey{}
+}
+
+func (s resultSorter) cursor(r RestaurantResult) searchCursor {
+	return searchCursor{Sort: s.field, Descending: s.descending, Key: s.key(r), ID: r.RestaurantID}
+}
+
+// compare orders two cursor positions: by sort key in the requested
+// direction, then by restaurant ID.
+func (s resultSorter) compare(a, b searchCursor) int {
+	if a.Key.Missing != b.Key.Missing {
+		if a.Key.Missing {
+			return 1
+		}
+		return -1
+	}
+
+	c := strings.Compare(a.Key.Text, b.Key.Text)
+	if c == 0 {
+		c = cmp.Compare(a.Key.Number, b.Key.Number)
+	}
+	if s.descending {
+		c = -c
+	}
+	if c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+func (s resultSorter) sort(results []RestaurantResult) {
+	sort.Slice(results, func(i, j int) bool {
+		return s.compare(s.cursor(results[i]), s.cursor(results[j])) < 0
+	})
+}
+
+// paginate sorts the results and returns the page that follows the cursor.
+func paginate(results []RestaurantResult, page PageRequest, filters SearchFilters) (SearchPage, error) {
+	sorter, err := newResultSorter(page, filters)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	sorter.sort(results)
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if page.All {
+		limit = len(results)
+	}
+
+	start := 0
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return SearchPage{}, err
+		}
+		// A cursor only makes sense for the order it was issued for
+		if cursor.Sort != sorter.field || cursor.Descending != sorter.descending {
+			return SearchPage{}, ErrInvalidCursor
+		}
+
+		// Skip everything up to and including the cursor position
+		start = sort.Search(len(results), func(i int) bool {
+			return sorter.compare(sorter.cursor(results[i]), cursor) > 0
+		})
+	}
+
+	end := start + limit
+	if end > len(results) {
+		end = len(results)
+	}
+
+	searchPage := SearchPage{Results: results[start:end]}
+	if searchPage.Results == nil {
+		searchPage.Results = []RestaurantResult{}
+	}
+	if end < len(results) {
+		searchPage.NextCursor = encodeCursor(sorter.cursor(results[end-1]))
+	}
+	return searchPage, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"server/models"
+)
+
+// paginationResults returns search results that differ in every sort field,
+// with ties and missing values, matching the filters of the second value.
+func paginationResults(t *testing.T) ([]RestaurantResult, SearchFilters) {
+	t.Helper()
+
+	cuisines := []string{"Pizza", "Pizza", "Sushi", "Tacos"}
+	hours := []string{"00:00-24:00", "Closed", "06:00-05:00", "Closed"}
+	var restaurants []models.Restaurant
+	for i := 0; i < 11; i++ {
+		r := models.Restaurant{
+			RestaurantID: strconv.Itoa(i + 1),
+			Name:         "Pizza " + string(rune('A'+i%5)),
+			CuisineType:  cuisines[i%len(cuisines)],
+			Address:      strconv.Itoa(i) + " Main St, Springfield, USA",
+			Timezone:     "UTC",
+			Location:     &models.Location{Latitude: 40 + float64(i%6)/10, Longitude: -74},
+			PriceLevel:   i % 5, // 0 is unknown
+			RatingSum:    float64(i % 4 * (1 + i%5)),
+			RatingCount:  i % 4, // 0 is unrated
+			OpeningHours: models.WeeklySchedule{},
+		}
+		r.NormalizeRating()
+		for day := 0; day < 7; day++ {
+			r.OpeningHours[time.Weekday(day)] = mustParseDay(t, hours[i%len(hours)])
+		}
+		restaurants = append(restaurants, r)
+	}
+
+	filters := SearchFilters{
+		Query: "pizza main",
+		Near:  &models.Location{Latitude: 40, Longitude: -74},
+	}
+	return filterRestaurants(restaurants, filters, at(2, 12, 0, time.UTC)), filters
+}
+
+func TestPaginateCursorRoundTrip(t *testing.T) {
+	sorts := []string{"", SortName, SortCuisine, SortDistance, SortRelevance, SortClosing, SortPrice, SortRating}
+
+	for _, field := range sorts {
+		for _, descending := range []bool{false, true} {
+			t.Run(field+"/"+strconv.FormatBool(descending), func(t *testing.T) {
+				results, filters := paginationResults(t)
+				if len(results) == 0 {
+					t.Fatal("no results to paginate")
+				}
+
+				all, err := paginate(results, PageRequest{Limit: MaxPageSize, Sort: field, Descending: descending}, filters)
+				if err != nil {
+					t.Fatalf("paginate: %v", err)
+				}
+				if all.NextCursor != "" {
+					t.Fatalf("single page has next cursor %q", all.NextCursor)
+				}
+
+				var paged []string
+				page := PageRequest{Limit: 3, Sort: field, Descending: descending}
+				for {
+					got, err := paginate(results, page, filters)
+					if err != nil {
+						t.Fatalf("paginate after %v: %v", paged, err)
+					}
+					for _, r := range got.Results {
+						paged = append(paged, r.RestaurantID)
+					}
+					if got.NextCursor == "" {
+						break
+					}
+					if len(paged) > len(results) {
+						t.Fatalf("pages do not end: %v", paged)
+					}
+
+					cursor, err := decodeCursor(got.NextCursor)
+					if err != nil {
+						t.Fatalf("decodeCursor(%q): %v", got.NextCursor, err)
+					}
+					if again := encodeCursor(cursor); again != got.NextCursor {
+						t.Errorf("cursor %q re-encodes as %q", got.NextCursor, again)
+					}
+					page.Cursor = got.NextCursor
+				}
+
+				var want []string
+				for _, r := range all.Results {
+					want = append(want, r.RestaurantID)
+				}
+				if !reflect.DeepEqual(paged, want) {
+					t.Errorf("pages = %v, want %v", paged, want)
+				}
+			})
+		}
+	}
+}
+
+func TestPaginateRejectsForeignCursor(t *testing.T) {
+	results, filters := paginationResults(t)
+
+	first, err := paginate(results, PageRequest{Limit: 2, Sort: SortName}, filters)
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		page PageRequest
+	}{
+		{name: "other sort", page: PageRequest{Cursor: first.NextCursor, Sort: SortPrice}},
+		{name: "other order", page: PageRequest{Cursor: first.NextCursor, Sort: SortName, Descending: true}},
+		{name: "not base64", page: PageRequest{Cursor: "!!!", Sort: SortName}},
+		{name: "no restaurant ID", page: PageRequest{Cursor: encodeCursor(searchCursor{Sort: SortName}), Sort: SortName}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := paginate(results, tt.page, filters); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("paginate error = %v, want %v", err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestPaginateAll(t *testing.T) {
+	results, filters := paginationResults(t)
+
+	got, err := paginate(results, PageRequest{All: true, Sort: SortName}, filters)
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+	if len(got.Results) != len(results) || got.NextCursor != "" {
+		t.Errorf("paginate returned %d of %d results with next cursor %q, want all of them", len(got.Results), len(results), got.NextCursor)
+	}
+}
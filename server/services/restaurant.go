@@ -4,207 +4,430 @@ import (
 	"context"
 	"errors"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
 	"server/models"
-
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 type SearchFilters struct {
-	Cuisine  string
 	IsKosher string
 	IsOpen   string
-}
 
-func SearchRestaurants(ctx context.Context, client *dynamodb.Client, tableName string, filters SearchFilters) ([]models.Restaurant, error) {
-	var restaurants []models.Restaurant
-
-	// Initialize ScanInput
-	input := &dynamodb.ScanInput{
-		TableName: &tableName,
-	}
+	// DietaryTags keeps restaurants that have all of the listed tags.
+	DietaryTags []string
+
+	// MinPrice and MaxPrice bound the price level, and MinRating the rating.
+	// Zero means no bound; restaurants without a price level or rating are
+	// left out once the corresponding bound is set.
+	MinPrice  int
+	MaxPrice  int
+	MinRating float64
+
+	// Cuisines, Cities and Countries keep restaurants matching any of the
+	// listed values; the Exclude lists drop restaurants matching any of
+	// theirs. All of them are case-insensitive.
+	Cuisines         []string
+	ExcludeCuisines  []string
+	Cities           []string
+	ExcludeCities    []string
+	Countries        []string
+	ExcludeCountries []string
+
+	// Query is free text matched against the name, cuisine and address with
+	// case and diacritic folding and typo tolerance. Unless another sort is
+	// requested, matches are ordered by relevance.
+	Query string
+
+	// OpenAt keeps restaurants open at that time instead of right now. When
+	// OpenAtLocal is set it is a wall-clock time read in each restaurant's own
+	// timezone, otherwise an absolute instant. OpenFor additionally requires
+	// the restaurant to stay open for that long.
+	OpenAt      *time.Time
+	OpenAtLocal bool
+	OpenFor     time.Duration
+
+	// IncludeInactive keeps temporarily and permanently closed restaurants,
+	// which are left out of search results by default.
+	IncludeInactive bool
+
+	// Near restricts results to restaurants with known coordinates and, unless
+	// another sort is requested, orders them by distance. RadiusKm, when positive, caps that distance.
+	Near     *models.Location
+	RadiusKm float64
+}
 
-	// Handle pagination
-	for {
-		result, err := client.Scan(ctx, input)
-		if err != nil {
-			return nil, err
-		}
+// RestaurantResult is a restaurant as returned by search, with values computed for the request.
+// Opening times are reported in the restaurant's own timezone.
+type RestaurantResult struct {
+	models.Restaurant
+	DistanceKm      *float64   `json:"distance_km,omitempty"`
+	Relevance       *float64   `json:"relevance,omitempty"`
+	IsOpenNow       bool       `json:"is_open_now"`
+	ClosesAt        *time.Time `json:"closes_at,omitempty"`
+	NextOpening     *time.Time `json:"next_opening,omitempty"`
+	KosherCertified bool       `json:"kosher_certified"` // what kosher searches match: is_kosher with an unexpired certificate
+}
 
-		var batch []models.Restaurant
-		err = attributevalue.UnmarshalListOfMaps(result.Items, &batch)
-		if err != nil {
-			return nil, err
-		}
-		restaurants = append(restaurants, batch...)
+// NewRestaurantResult wraps a restaurant with its opening status at the given instant.
+func NewRestaurantResult(r models.Restaurant, now time.Time) RestaurantResult {
+	result := RestaurantResult{Restaurant: r}
+	result.IsOpenNow, result.ClosesAt, result.NextOpening = openingStatus(r, now)
+	result.KosherCertified = isCertifiedKosher(r, now)
+	return result
+}
 
-		if result.LastEvaluatedKey == nil {
-			break
-		}
-		input.ExclusiveStartKey = result.LastEvaluatedKey
+func SearchRestaurants(ctx context.Context, store RestaurantStore, filters SearchFilters, page PageRequest) (SearchPage, error) {
+	// Let the store narrow the candidates down with its indexes
+	restaurants, err := store.QueryRestaurants(ctx, filters.storeQuery())
+	if err != nil {
+		return SearchPage{}, err
 	}
 
 	// Apply in-memory filtering
-	filtered := filterRestaurants(restaurants, filters)
+	now := time.Now()
+	filtered := filterRestaurants(restaurants, filters, now)
 
-	if len(filtered) == 0 {
-		return nil, errors.New("no matching restaurants found")
+	// Sort and cut out the requested page
+	searchPage, err := paginate(filtered, page, filters)
+	if err != nil {
+		return SearchPage{}, err
 	}
 
-	return filtered, nil
+	// Count facets over every match, not just this page
+	searchPage.Facets = countFacets(filtered, now)
+	return searchPage, nil
 }
 
-func filterRestaurants(restaurants []models.Restaurant, filters SearchFilters) []models.Restaurant {
-	var filtered []models.Restaurant
+// filterRestaurants keeps the restaurants matching the filters, judging
+// opening hours and certificates as of now.
+func filterRestaurants(restaurants []models.Restaurant, filters SearchFilters, now time.Time) []RestaurantResult {
+	queryTokens := tokenize(filters.Query)
+
+	var filtered []RestaurantResult
 	for _, r := range restaurants {
+		// Filter by status
+		if !filters.IncludeInactive && !isRestaurantActive(r, now) {
+			continue
+		}
+
+		result := NewRestaurantResult(r, now)
+
+		// Filter by free text
+		if len(queryTokens) > 0 {
+			score := textScore(queryTokens, r)
+			if score == 0 {
+				continue
+			}
+			result.Relevance = &score
+		}
+
+		// Filter by distance
+		if filters.Near != nil {
+			if r.Location == nil {
+				continue
+			}
+			distance := DistanceKm(*filters.Near, *r.Location)
+			if filters.RadiusKm > 0 && distance > filters.RadiusKm {
+				continue
+			}
+			result.DistanceKm = &distance
+		}
+
 		// Filter by Cuisine
-		if filters.Cuisine != "" && !strings.EqualFold(r.CuisineType, filters.Cuisine) {
+		if len(filters.Cuisines) > 0 && !matchesCuisine(r, filters.Cuisines) {
+			continue
+		}
+		if matchesCuisine(r, filters.ExcludeCuisines) {
+			continue
+		}
+
+		// Filter by City
+		if len(filters.Cities) > 0 && !matchesPlace(restaurantCity(r), filters.Cities) {
+			continue
+		}
+		if matchesPlace(restaurantCity(r), filters.ExcludeCities) {
+			continue
+		}
+
+		// Filter by Country
+		if len(filters.Countries) > 0 && !matchesPlace(restaurantCountry(r), filters.Countries) {
+			continue
+		}
+		if matchesPlace(restaurantCountry(r), filters.ExcludeCountries) {
 			continue
 		}
 
 		// Filter by Kosher
 		if filters.IsKosher != "" {
 			isKosher := strings.EqualFold(filters.IsKosher, "true")
-			if r.IsKosher != isKosher {
+			if isCertifiedKosher(r, now) != isKosher {
 				continue
 			}
 		}
 
-		// Filter by Currently Open
-		if filters.IsOpen != "" && strings.EqualFold(filters.IsOpen, "true") {
-			if !isRestaurantOpen(r.OpeningHours) {
+		// Filter by dietary tags
+		if !hasAllDietaryTags(r, filters.DietaryTags, now) {
+			continue
+		}
+
+		// Filter by price level and rating
+		if (filters.MinPrice > 0 || filters.MaxPrice > 0) && !inPriceRange(r.PriceLevel, filters.MinPrice, filters.MaxPrice) {
+			continue
+		}
+		if filters.MinRating > 0 && r.Rating < filters.MinRating {
+			continue
+		}
+
+		// Filter by opening status, either now or at the requested time
+		if at, ok := filters.openingCheckTime(r, now); ok {
+			if !isRestaurantOpenFor(r, at, filters.OpenFor) {
 				continue
 			}
 		}
 
 		// If all filters match, add the restaurant
-		filtered = append(filtered, r)
+		filtered = append(filtered, result)
 	}
 
 	return filtered
 }
 
-func FetchRestaurantByID(ctx context.Context, client *dynamodb.Client, tableName string, restaurantID string) (*models.Restaurant, error) {
-	// Prepare the key for querying the item
-	input := &dynamodb.GetItemInput{
-		TableName: &tableName,
-		Key: map[string]types.AttributeValue{
-			"restaurant_id": &types.AttributeValueMemberS{Value: restaurantID},
-		},
+// matchesCuisine reports whether the restaurant's cuisine is one of the given cuisines.
+func matchesCuisine(r models.Restaurant, cuisines []string) bool {
+	for _, cuisine := range cuisines {
+		if strings.EqualFold(r.CuisineType, strings.TrimSpace(cuisine)) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Fetch the item from DynamoDB
-	result, err := client.GetItem(ctx, input)
-	if err != nil {
-		return nil, err
+// hasAllDietaryTags reports whether the restaurant has every one of the tags.
+// The kosher tag also needs a valid certificate, as for is_kosher.
+func hasAllDietaryTags(r models.Restaurant, tags []string, now time.Time) bool {
+	for _, tag := range tags {
+		if strings.EqualFold(strings.TrimSpace(tag), models.DietKosher) {
+			if !isCertifiedKosher(r, now) {
+				return false
+			}
+			continue
+		}
+		if !r.HasDietaryTag(tag) {
+			return false
+		}
 	}
+	return true
+}
 
-	// Check if the item exists
-	if result.Item == nil {
-		return nil, errors.New("restaurant not found")
+// inPriceRange reports whether a known price level lies within the bounds, where 0 means unbounded.
+func inPriceRange(level, min, max int) bool {
+	if level == 0 {
+		return false
 	}
+	return (min == 0 || level >= min) && (max == 0 || level <= max)
+}
 
-	// Unmarshal the item into a Restaurant struct
-	var restaurant models.Restaurant
-	err = attributevalue.UnmarshalMap(result.Item, &restaurant)
-	if err != nil {
-		return nil, err
+// restaurantCity returns the restaurant's city, parsed from the one-line
+// address for restaurants stored before addresses were structured.
+func restaurantCity(r models.Restaurant) string {
+	if r.City != "" {
+		return r.City
 	}
+	return models.ParseAddress(r.Address).City
+}
 
-	return &restaurant, nil
+// restaurantCountry returns the restaurant's country, like restaurantCity.
+func restaurantCountry(r models.Restaurant) string {
+	if r.Country != "" {
+		return r.Country
+	}
+	return models.ParseAddress(r.Address).Country
 }
 
-func isRestaurantOpen(openingHours map[string]string) bool {
-	// Get current day and time
-	now := time.Now()
-	currentDay := now.Weekday().String()
-	currentTime := now.Format("15:04")
+// placeKey normalizes a city or country name for matching, ignoring case and diacritics.
+func placeKey(place string) string {
+	return foldText(strings.TrimSpace(place))
+}
 
-	// Check if the restaurant is open on the current day
-	hours, ok := openingHours[currentDay]
-	if !ok || hours == "Closed" {
+// matchesPlace reports whether the city or country is one of the given places.
+func matchesPlace(place string, places []string) bool {
+	key := placeKey(place)
+	if key == "" {
 		return false
 	}
+	for _, p := range places {
+		if placeKey(p) == key {
+			return true
+		}
+	}
+	return false
+}
 
-	// Parse opening and closing times
-	times := strings.Split(hours, "-")
-	if len(times) != 2 {
-		return false
+// storeQuery returns the part of the filters that the store can serve from an index.
+func (f SearchFilters) storeQuery() RestaurantQuery {
+	query := RestaurantQuery{
+		Cuisines:  f.Cuisines,
+		Cities:    f.Cities,
+		Countries: f.Countries,
+
+		DietaryTags: f.DietaryTags,
+		MinPrice:    f.MinPrice,
+		MaxPrice:    f.MaxPrice,
+		Text:        f.Query,
+		Near:        f.Near,
+		RadiusKm:    f.RadiusKm,
 	}
+	// Restaurants marked kosher may have an expired certificate, so only
+	// is_kosher=true narrows the candidates
+	if strings.EqualFold(f.IsKosher, "true") {
+		isKosher := true
+		query.IsKosher = &isKosher
+	}
+	return query
+}
 
-	openTime, err := time.Parse("15:04", times[0])
-	if err != nil {
-		return false
+// openingCheckTime returns the instant at which the restaurant must be open, if the filters ask for one.
+func (f SearchFilters) openingCheckTime(r models.Restaurant, now time.Time) (time.Time, bool) {
+	if f.OpenAt != nil {
+		if !f.OpenAtLocal {
+			return *f.OpenAt, true
+		}
+		at := *f.OpenAt
+		return time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), at.Minute(), at.Second(), 0, restaurantTimezone(r)), true
 	}
-	closeTime, err := time.Parse("15:04", times[1])
-	if err != nil {
-		return false
+
+	if strings.EqualFold(f.IsOpen, "true") {
+		return now, true
 	}
+	return time.Time{}, false
+}
 
-	return currentTime >= openTime.Format("15:04") && currentTime <= closeTime.Format("15:04")
+func FetchRestaurantByID(ctx context.Context, store RestaurantStore, restaurantID string) (*models.Restaurant, error) {
+	return store.GetRestaurant(ctx, restaurantID)
 }
 
-func AddRestaurant(ctx context.Context, client *dynamodb.Client, tableName string, restaurant models.Restaurant) error {
-	// Log the restaurant object
-	log.Printf("Adding restaurant: %+v", restaurant)
+// isRestaurantOpen reports whether the restaurant is open at the given instant,
+// evaluated in the restaurant's own timezone.
+func isRestaurantOpen(r models.Restaurant, at time.Time) bool {
+	_, open := openUntil(r, at)
+	return open
+}
 
-	// Convert restaurant to DynamoDB item
-	item, err := attributevalue.MarshalMap(restaurant)
-	if err != nil {
-		log.Printf("Error marshalling restaurant: %v", err)
-		return err
-	}
+// AddRestaurant stores a new restaurant. Its rating is derived from reviews,
+// so new restaurants start unrated.
+func AddRestaurant(ctx context.Context, store RestaurantStore, restaurant models.Restaurant) error {
+	restaurant.Rating = 0
+	restaurant.RatingSum = 0
+	restaurant.RatingCount = 0
+	restaurant.FillAddressParts()
+	restaurant.NormalizeDietaryTags()
+	restaurant.Touch()
 
-	// Log the marshalled item
-	log.Printf("Marshalled item: %+v", item)
+	// Log the restaurant object
+	log.Printf("Adding restaurant: %+v", restaurant)
 
-	// Add the item to DynamoDB
-	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: &tableName,
-		Item:      item,
-	})
+	err := store.PutRestaurant(ctx, restaurant)
 	if err != nil {
 		log.Printf("Error inserting restaurant: %v", err)
 		return err
 	}
 
-	log.Println("Successfully inserted restaurant into DynamoDB")
+	log.Println("Successfully inserted restaurant")
 	return nil
 }
 
-func RemoveRestaurant(ctx context.Context, client *dynamodb.Client, tableName string, restaurantID string) error {
-	// Build key for deletion
-	key, err := attributevalue.MarshalMap(map[string]string{
-		"restaurant_id": restaurantID,
-	})
-	if err != nil {
+// RemoveRestaurant deletes a restaurant and its reviews, so that none are
+// left in the moderation queue and a restaurant added again under the same ID
+// starts without them. If the reviews cannot be deleted, removing the
+// restaurant again retries.
+func RemoveRestaurant(ctx context.Context, store RestaurantStore, reviews ReviewStore, restaurantID string) error {
+	if err := store.DeleteRestaurant(ctx, restaurantID); err != nil {
 		return err
 	}
+	return reviews.DeleteReviews(ctx, restaurantID)
+}
 
-	// Remove the restaurant from DynamoDB
-	_, err = client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: &tableName,
-		Key:       key,
+// EditRestaurant replaces a restaurant's details. The rating is derived from
+// reviews, so it is kept from the stored restaurant rather than the request.
+func EditRestaurant(ctx context.Context, store RestaurantStore, restaurant models.Restaurant) error {
+	restaurant.FillAddressParts()
+	restaurant.NormalizeDietaryTags()
+
+	_, err := updateRestaurant(ctx, store, restaurant.RestaurantID, func(stored *models.Restaurant) {
+		edited := restaurant
+		edited.Rating = stored.Rating
+		edited.RatingSum = stored.RatingSum
+		edited.RatingCount = stored.RatingCount
+		*stored = edited
 	})
-
 	return err
 }
 
-func EditRestaurant(ctx context.Context, client *dynamodb.Client, tableName string, restaurant models.Restaurant) error {
-	item, err := attributevalue.MarshalMap(restaurant)
-	if err != nil {
-		return err
+// maxUpdateAttempts bounds how often updateRestaurant starts over when reviews
+// keep changing the rating.
+const maxUpdateAttempts = 5
+
+// updateRestaurant reads a restaurant, applies change to it and writes it
+// back, starting over if a review changed the rating in the meantime.
+func updateRestaurant(ctx context.Context, store RestaurantStore, restaurantID string, change func(*models.Restaurant)) (*models.Restaurant, error) {
+	for attempt := 1; ; attempt++ {
+		restaurant, err := store.GetRestaurant(ctx, restaurantID)
+		if err != nil {
+			return nil, err
+		}
+		change(restaurant)
+		restaurant.Touch()
+
+		err = store.PutRestaurant(ctx, *restaurant)
+		if errors.Is(err, ErrRatingChanged) && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return restaurant, nil
 	}
+}
+
+// SetSpecialHours adds or replaces a restaurant's special hours for one date.
+func SetSpecialHours(ctx context.Context, store RestaurantStore, restaurantID string, special models.SpecialHours) (*models.Restaurant, error) {
+	return updateRestaurant(ctx, store, restaurantID, func(restaurant *models.Restaurant) {
+		var updated []models.SpecialHours
+		for _, existing := range restaurant.SpecialHours {
+			if existing.Date != special.Date {
+				updated = append(updated, existing)
+			}
+		}
+		updated = append(updated, special)
+
+		// Keep the exceptions in calendar order
+		sort.Slice(updated, func(i, j int) bool {
+			return updated[i].Date < updated[j].Date
+		})
+		restaurant.SpecialHours = updated
+	})
+}
 
-	// Update the restaurant in DynamoDB
-	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: &tableName,
-		Item:      item,
+// RemoveSpecialHours deletes a restaurant's special hours for one date, if any.
+func RemoveSpecialHours(ctx context.Context, store RestaurantStore, restaurantID string, date string) (*models.Restaurant, error) {
+	return updateRestaurant(ctx, store, restaurantID, func(restaurant *models.Restaurant) {
+		var updated []models.SpecialHours
+		for _, existing := range restaurant.SpecialHours {
+			if existing.Date != date {
+				updated = append(updated, existing)
+			}
+		}
+		restaurant.SpecialHours = updated
 	})
+}
 
-	return err
+// SetRestaurantStatus changes a restaurant's status without touching its other fields.
+func SetRestaurantStatus(ctx context.Context, store RestaurantStore, restaurantID, status, reason, reopenDate string) (*models.Restaurant, error) {
+	return updateRestaurant(ctx, store, restaurantID, func(restaurant *models.Restaurant) {
+		restaurant.Status = status
+		restaurant.StatusReason = reason
+		restaurant.ReopenDate = reopenDate
+	})
 }
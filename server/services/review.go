@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"server/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultReviewPageSize = 20
+	MaxReviewPageSize     = 100
+)
+
+// ReviewPage is one page of a restaurant's reviews, newest first.
+type ReviewPage struct {
+	Reviews    []models.Review `json:"reviews"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+var ErrReviewAlreadyModerated = errors.New("review already moderated")
+
+// AddReview stores a new review of an existing restaurant. The review is
+// pending until an admin approves it with ModerateReview.
+func AddReview(ctx context.Context, store RestaurantStore, reviews ReviewStore, review models.Review) (models.Review, error) {
+	if _, err := store.GetRestaurant(ctx, review.RestaurantID); err != nil {
+		return models.Review{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return models.Review{}, err
+	}
+	review.ReviewID = id.String()
+	review.CreatedAt = time.Now().UTC()
+	review.Status = models.ReviewPending
+
+	if err := reviews.PutReview(ctx, review); err != nil {
+		return models.Review{}, err
+	}
+
+	log.Printf("Added review %s for restaurant %s", review.ReviewID, review.RestaurantID)
+	return review, nil
+}
+
+// ListReviews returns a page of the restaurant's reviews. Cursor is the
+// NextCursor of the previous page, or empty for the newest reviews.
+func ListReviews(ctx context.Context, store RestaurantStore, reviews ReviewStore, restaurantID string, limit int, cursor string) (ReviewPage, error) {
+	if _, err := store.GetRestaurant(ctx, restaurantID); err != nil {
+		return ReviewPage{}, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultReviewPageSize
+	}
+	if limit > MaxReviewPageSize {
+		limit = MaxReviewPageSize
+	}
+
+	before, err := decodeReviewCursor(cursor)
+	if err != nil {
+		return ReviewPage{}, err
+	}
+
+	list, more, err := reviews.ListReviews(ctx, restaurantID, limit, before)
+	if err != nil {
+		return ReviewPage{}, err
+	}
+
+	// Moderation notes are for admins only
+	for i := range list {
+		list[i].StatusReason = ""
+		list[i].ModeratedAt = nil
+	}
+	return newReviewPage(list, more), nil
+}
+
+// ListPendingReviews returns a page of the moderation queue, oldest first.
+func ListPendingReviews(ctx context.Context, reviews ReviewStore, limit int, cursor string) (ReviewPage, error) {
+	if limit <= 0 {
+		limit = DefaultReviewPageSize
+	}
+	if limit > MaxReviewPageSize {
+		limit = MaxReviewPageSize
+	}
+
+	after, err := decodeReviewCursor(cursor)
+	if err != nil {
+		return ReviewPage{}, err
+	}
+
+	list, more, err := reviews.ListPendingReviews(ctx, limit, after)
+	if err != nil {
+		return ReviewPage{}, err
+	}
+	return newReviewPage(list, more), nil
+}
+
+// ModerateReview approves or rejects a pending review. Approved reviews are
+// shown publicly and folded into the restaurant's aggregate rating.
+//
+// The review leaves the pending status with a conditional write, so that of
+// concurrent decisions only one succeeds, and only that one counts the
+// rating. If counting the rating fails, the review is made pending again so
+// that the approval can be retried.
+func ModerateReview(ctx context.Context, store RestaurantStore, reviews ReviewStore, restaurantID, reviewID, status, reason string) (*models.Review, error) {
+	review, err := reviews.GetReview(ctx, restaurantID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.Status != models.ReviewPending {
+		return nil, ErrReviewAlreadyModerated
+	}
+	if status == models.ReviewApproved {
+		if _, err := store.GetRestaurant(ctx, restaurantID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	review.Status = status
+	review.StatusReason = reason
+	review.ModeratedAt = &now
+	err = reviews.UpdateReview(ctx, *review, models.ReviewPending)
+	if errors.Is(err, ErrReviewChanged) {
+		return nil, ErrReviewAlreadyModerated
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if status == models.ReviewApproved {
+		if _, err := store.AdjustRating(ctx, restaurantID, review.Rating, 1); err != nil {
+			pending := *review
+			pending.Status = models.ReviewPending
+			pending.StatusReason = ""
+			pending.ModeratedAt = nil
+			if err := reviews.UpdateReview(ctx, pending, models.ReviewApproved); err != nil {
+				log.Printf("Error returning review %s to pending: %v", reviewID, err)
+			}
+			return nil, err
+		}
+	}
+
+	log.Printf("Review %s for restaurant %s %s", reviewID, restaurantID, status)
+	return review, nil
+}
+
+// decodeReviewCursor returns the review ID a page cursor points at, or an
+// empty string for the first page.
+func decodeReviewCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(data), nil
+}
+
+func newReviewPage(list []models.Review, more bool) ReviewPage {
+	page := ReviewPage{Reviews: list}
+	if page.Reviews == nil {
+		page.Reviews = []models.Review{}
+	}
+	if more && len(list) > 0 {
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(list[len(list)-1].ReviewID))
+	}
+	return page
+}
+
+// DeleteReview removes a review and, if it was approved, takes its rating out
+// of the restaurant's aggregate rating.
+//
+// The review is only deleted if it still has the status it was read with, so
+// that a review approved meanwhile is not deleted without taking its rating
+// out. Otherwise DeleteReview fails with ErrReviewChanged.
+func DeleteReview(ctx context.Context, store RestaurantStore, reviews ReviewStore, restaurantID, reviewID string) error {
+	review, err := reviews.GetReview(ctx, restaurantID, reviewID)
+	if err != nil {
+		return err
+	}
+
+	if err := reviews.DeleteReview(ctx, restaurantID, reviewID, review.Status); err != nil {
+		return err
+	}
+	if !review.IsApproved() {
+		return nil
+	}
+
+	_, err = store.AdjustRating(ctx, restaurantID, -review.Rating, -1)
+	if errors.Is(err, ErrRestaurantNotFound) {
+		// The restaurant is gone, so there is no aggregate left to update
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrReviewNotFound = errors.New("review not found")
+
+// ErrReviewChanged is returned by conditional review writes when the stored
+// review no longer has the expected status, or was deleted.
+var ErrReviewChanged = errors.New("review changed")
+
+// ReviewStore is the persistence layer used for restaurant reviews.
+type ReviewStore interface {
+	PutReview(ctx context.Context, review models.Review) error
+	GetReview(ctx context.Context, restaurantID, reviewID string) (*models.Review, error)
+	// ListReviews returns up to limit approved reviews of the restaurant,
+	// newest first, starting after the review with ID before (or from the
+	// newest if empty), and whether more reviews follow.
+	ListReviews(ctx context.Context, restaurantID string, limit int, before string) ([]models.Review, bool, error)
+	// ListPendingReviews returns up to limit pending reviews of all
+	// restaurants, oldest first, starting after the review with ID after (or
+	// from the oldest if empty), and whether more reviews follow.
+	ListPendingReviews(ctx context.Context, limit int, after string) ([]models.Review, bool, error)
+	// UpdateReview replaces a stored review if it still has status from, and
+	// fails with ErrReviewChanged otherwise.
+	UpdateReview(ctx context.Context, review models.Review, from string) error
+	// DeleteReview removes a review if it still has the given status, and
+	// fails with ErrReviewChanged otherwise.
+	DeleteReview(ctx context.Context, restaurantID, reviewID, status string) error
+	// DeleteReviews removes all reviews of the restaurant, whatever their status.
+	DeleteReviews(ctx context.Context, restaurantID string) error
+}
+
+// Pending reviews carry a pending_key attribute, so that the sparse
+// pending_key-index holds only the moderation queue.
+const (
+	pendingIndexName = "pending_key-index"
+	pendingKeyAttr   = "pending_key"
+)
+
+// DynamoReviewStore keeps reviews in a DynamoDB table keyed by restaurant_id
+// and review_id, defined in infra/main.tf.
+type DynamoReviewStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoReviewStore(client *dynamodb.Client, tableName string) *DynamoReviewStore {
+	return &DynamoReviewStore{client: client, tableName: tableName}
+}
+
+func reviewKey(restaurantID, reviewID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"restaurant_id": &types.AttributeValueMemberS{Value: restaurantID},
+		"review_id":     &types.AttributeValueMemberS{Value: reviewID},
+	}
+}
+
+// marshalReviewItem converts a review to a DynamoDB item, including the pending key.
+func marshalReviewItem(review models.Review) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(review)
+	if err != nil {
+		return nil, err
+	}
+	if review.Status == models.ReviewPending {
+		item[pendingKeyAttr] = &types.AttributeValueMemberS{Value: models.ReviewPending}
+	}
+	return item, nil
+}
+
+// hasStatus returns a condition that holds if the review exists and has the
+// status. Reviews posted before moderation are stored without one.
+func hasStatus(status string) (string, map[string]string, map[string]types.AttributeValue) {
+	names := map[string]string{"#rid": "restaurant_id", "#st": "status"}
+	if status == "" {
+		return "attribute_exists(#rid) AND attribute_not_exists(#st)", names, nil
+	}
+	values := map[string]types.AttributeValue{
+		":st": &types.AttributeValueMemberS{Value: status},
+	}
+	return "attribute_exists(#rid) AND #st = :st", names, values
+}
+
+func (s *DynamoReviewStore) PutReview(ctx context.Context, review models.Review) error {
+	item, err := marshalReviewItem(review)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	})
+	return err
+}
+
+// UpdateReview conditions the write on the stored status, so that of two
+// replicas moderating the same review at once only one succeeds.
+func (s *DynamoReviewStore) UpdateReview(ctx context.Context, review models.Review, from string) error {
+	item, err := marshalReviewItem(review)
+	if err != nil {
+		return err
+	}
+
+	condition, names, values := hasStatus(from)
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(s.tableName),
+		Item:                      item,
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	var changed *types.ConditionalCheckFailedException
+	if errors.As(err, &changed) {
+		return ErrReviewChanged
+	}
+	return err
+}
+
+func (s *DynamoReviewStore) GetReview(ctx context.Context, restaurantID, reviewID string) (*models.Review, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       reviewKey(restaurantID, reviewID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrReviewNotFound
+	}
+
+	var review models.Review
+	if err := attributevalue.UnmarshalMap(result.Item, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ListReviews queries the restaurant's partition backwards, asking for one
+// review more than the limit to learn whether another page follows.
+func (s *DynamoReviewStore) ListReviews(ctx context.Context, restaurantID string, limit int, before string) ([]models.Review, bool, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("#rid = :rid"),
+		FilterExpression:       aws.String("attribute_not_exists(#st) OR #st = :approved"),
+		ExpressionAttributeNames: map[string]string{
+			"#rid": "restaurant_id",
+			"#st":  "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rid":      &types.AttributeValueMemberS{Value: restaurantID},
+			":approved": &types.AttributeValueMemberS{Value: models.ReviewApproved},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(int32(limit + 1)),
+	}
+	if before != "" {
+		input.KeyConditionExpression = aws.String("#rid = :rid AND #id < :before")
+		input.ExpressionAttributeNames["#id"] = "review_id"
+		input.ExpressionAttributeValues[":before"] = &types.AttributeValueMemberS{Value: before}
+	}
+
+	return s.queryPage(ctx, input, limit)
+}
+
+// ListPendingReviews reads the moderation queue from the pending_key-index,
+// which is sorted by review ID and so by when the reviews were posted.
+func (s *DynamoReviewStore) ListPendingReviews(ctx context.Context, limit int, after string) ([]models.Review, bool, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		IndexName:              aws.String(pendingIndexName),
+		KeyConditionExpression: aws.String("#pk = :pending"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": pendingKeyAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: models.ReviewPending},
+		},
+		Limit: aws.Int32(int32(limit + 1)),
+	}
+	if after != "" {
+		input.KeyConditionExpression = aws.String("#pk = :pending AND #id > :after")
+		input.ExpressionAttributeNames["#id"] = "review_id"
+		input.ExpressionAttributeValues[":after"] = &types.AttributeValueMemberS{Value: after}
+	}
+
+	return s.queryPage(ctx, input, limit)
+}
+
+// queryPage runs a query until it has collected more than limit reviews or
+// reached the end, and returns the first limit reviews and whether more follow.
+func (s *DynamoReviewStore) queryPage(ctx context.Context, input *dynamodb.QueryInput, limit int) ([]models.Review, bool, error) {
+	var reviews []models.Review
+
+	// Handle pagination until enough reviews are collected
+	for len(reviews) <= limit {
+		result, err := s.client.Query(ctx, input)
+		if err != nil {
+			return nil, false, err
+		}
+
+		var batch []models.Review
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &batch); err != nil {
+			return nil, false, err
+		}
+		reviews = append(reviews, batch...)
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	if len(reviews) > limit {
+		return reviews[:limit], true, nil
+	}
+	return reviews, false, nil
+}
+
+func (s *DynamoReviewStore) DeleteReview(ctx context.Context, restaurantID, reviewID, status string) error {
+	condition, names, values := hasStatus(status)
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(s.tableName),
+		Key:                       reviewKey(restaurantID, reviewID),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	var changed *types.ConditionalCheckFailedException
+	if errors.As(err, &changed) {
+		return ErrReviewChanged
+	}
+	return err
+}
+
+// DeleteReviews queries the keys in the restaurant's partition and deletes
+// them in batches of 25, the most BatchWriteItem accepts.
+func (s *DynamoReviewStore) DeleteReviews(ctx context.Context, restaurantID string) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("#rid = :rid"),
+		ProjectionExpression:   aws.String("#rid, #id"),
+		ExpressionAttributeNames: map[string]string{
+			"#rid": "restaurant_id",
+			"#id":  "review_id",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rid": &types.AttributeValueMemberS{Value: restaurantID},
+		},
+	}
+
+	for {
+		result, err := s.client.Query(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for start := 0; start < len(result.Items); start += 25 {
+			end := min(start+25, len(result.Items))
+			var requests []types.WriteRequest
+			for _, key := range result.Items[start:end] {
+				requests = append(requests, types.WriteRequest{
+					DeleteRequest: &types.DeleteRequest{Key: key},
+				})
+			}
+			if err := s.batchWrite(ctx, requests); err != nil {
+				return err
+			}
+		}
+
+		if result.LastEvaluatedKey == nil {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// batchWrite sends the requests, resending any that DynamoDB leaves
+// unprocessed when the table is throttled.
+func (s *DynamoReviewStore) batchWrite(ctx context.Context, requests []types.WriteRequest) error {
+	for attempt := 0; len(requests) > 0; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		}
+
+		result, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{s.tableName: requests},
+		})
+		if err != nil {
+			return err
+		}
+		requests = result.UnprocessedItems[s.tableName]
+	}
+	return nil
+}
+
+// MemoryReviewStore keeps reviews in process memory. It is meant for tests
+// and local development without AWS access.
+type MemoryReviewStore struct {
+	mu      sync.RWMutex
+	reviews map[string][]models.Review // by restaurant, oldest first
+}
+
+func NewMemoryReviewStore() *MemoryReviewStore {
+	return &MemoryReviewStore{reviews: make(map[string][]models.Review)}
+}
+
+func (s *MemoryReviewStore) PutReview(ctx context.Context, review models.Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := s.reviews[review.RestaurantID]
+	i := sort.Search(len(reviews), func(i int) bool {
+		return reviews[i].ReviewID >= review.ReviewID
+	})
+	if i < len(reviews) && reviews[i].ReviewID == review.ReviewID {
+		reviews[i] = review
+		return nil
+	}
+	s.reviews[review.RestaurantID] = append(reviews[:i], append([]models.Review{review}, reviews[i:]...)...)
+	return nil
+}
+
+func (s *MemoryReviewStore) GetReview(ctx context.Context, restaurantID, reviewID string) (*models.Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, review := range s.reviews[restaurantID] {
+		if review.ReviewID == reviewID {
+			return &review, nil
+		}
+	}
+	return nil, ErrReviewNotFound
+}
+
+func (s *MemoryReviewStore) ListReviews(ctx context.Context, restaurantID string, limit int, before string) ([]models.Review, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reviews []models.Review
+	all := s.reviews[restaurantID]
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].IsApproved() || (before != "" && all[i].ReviewID >= before) {
+			continue
+		}
+		if len(reviews) == limit {
+			return reviews, true, nil
+		}
+		reviews = append(reviews, all[i])
+	}
+	return reviews, false, nil
+}
+
+func (s *MemoryReviewStore) ListPendingReviews(ctx context.Context, limit int, after string) ([]models.Review, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []models.Review
+	for _, reviews := range s.reviews {
+		for _, review := range reviews {
+			if review.Status == models.ReviewPending && review.ReviewID > after {
+				pending = append(pending, review)
+			}
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ReviewID < pending[j].ReviewID
+	})
+
+	if len(pending) > limit {
+		return pending[:limit], true, nil
+	}
+	return pending, false, nil
+}
+
+func (s *MemoryReviewStore) UpdateReview(ctx context.Context, review models.Review, from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.reviews[review.RestaurantID] {
+		if stored.ReviewID == review.ReviewID {
+			if stored.Status != from {
+				return ErrReviewChanged
+			}
+			s.reviews[review.RestaurantID][i] = review
+			return nil
+		}
+	}
+	return ErrReviewChanged
+}
+
+func (s *MemoryReviewStore) DeleteReview(ctx context.Context, restaurantID, reviewID, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := s.reviews[restaurantID]
+	for i, review := range reviews {
+		if review.ReviewID == reviewID {
+			if review.Status != status {
+				return ErrReviewChanged
+			}
+			s.reviews[restaurantID] = append(reviews[:i], reviews[i+1:]...)
+			return nil
+		}
+	}
+	return ErrReviewChanged
+}
+
+func (s *MemoryReviewStore) DeleteReviews(ctx context.Context, restaurantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reviews, restaurantID)
+	return nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"server/models"
+)
+
+func TestReviewRating(t *testing.T) {
+	type step struct {
+		approve int // rating of a new review to approve, or 0
+		remove  int // index of an approved review to delete, or -1
+	}
+	approve := func(rating int) step { return step{approve: rating, remove: -1} }
+	remove := func(i int) step { return step{remove: i} }
+
+	tests := []struct {
+		name       string
+		restaurant models.Restaurant
+		steps      []step
+		wantRating float64
+		wantCount  int
+	}{
+		{name: "first rating", steps: []step{approve(4)}, wantRating: 4, wantCount: 1},
+		{name: "average", steps: []step{approve(4), approve(5), approve(3)}, wantRating: 4, wantCount: 3},
+		{name: "remove a rating", steps: []step{approve(1), approve(5), remove(0)}, wantRating: 5, wantCount: 1},
+		{name: "remove the last rating", steps: []step{approve(2), remove(0)}, wantRating: 0, wantCount: 0},
+		{name: "add after removing", steps: []step{approve(2), remove(0), approve(5)}, wantRating: 5, wantCount: 1},
+		{
+			name:       "rating stored as an average",
+			restaurant: models.Restaurant{Rating: 4, RatingCount: 2},
+			steps:      []step{approve(1)},
+			wantRating: 3,
+			wantCount:  3,
+		},
+		{
+			name:       "remove from a rating stored as an average",
+			restaurant: models.Restaurant{Rating: 3.5, RatingCount: 2},
+			steps:      []step{approve(5), remove(0)},
+			wantRating: 3.5,
+			wantCount:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tt.restaurant.RestaurantID = "1"
+			store := NewMemoryRestaurantStore(tt.restaurant)
+			reviews := NewMemoryReviewStore()
+
+			var approved []models.Review
+			for _, s := range tt.steps {
+				if s.approve != 0 {
+					review, err := AddReview(ctx, store, reviews, models.Review{RestaurantID: "1", Rating: s.approve, Author: "A"})
+					if err != nil {
+						t.Fatalf("AddReview: %v", err)
+					}
+					if _, err := ModerateReview(ctx, store, reviews, "1", review.ReviewID, models.ReviewApproved, ""); err != nil {
+						t.Fatalf("ModerateReview: %v", err)
+					}
+					approved = append(approved, review)
+					continue
+				}
+				if err := DeleteReview(ctx, store, reviews, "1", approved[s.remove].ReviewID); err != nil {
+					t.Fatalf("DeleteReview: %v", err)
+				}
+			}
+
+			restaurant, err := store.GetRestaurant(ctx, "1")
+			if err != nil {
+				t.Fatalf("GetRestaurant: %v", err)
+			}
+			if math.Abs(restaurant.Rating-tt.wantRating) > 1e-9 || restaurant.RatingCount != tt.wantCount {
+				t.Errorf("rating = %v from %d ratings, want %v from %d", restaurant.Rating, restaurant.RatingCount, tt.wantRating, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestModerateReviewCountsOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRestaurantStore(models.Restaurant{RestaurantID: "1"})
+	reviews := NewMemoryReviewStore()
+
+	review, err := AddReview(ctx, store, reviews, models.Review{RestaurantID: "1", Rating: 5, Author: "A"})
+	if err != nil {
+		t.Fatalf("AddReview: %v", err)
+	}
+	if _, err := ModerateReview(ctx, store, reviews, "1", review.ReviewID, models.ReviewApproved, ""); err != nil {
+		t.Fatalf("ModerateReview: %v", err)
+	}
+	if _, err := ModerateReview(ctx, store, reviews, "1", review.ReviewID, models.ReviewApproved, ""); !errors.Is(err, ErrReviewAlreadyModerated) {
+		t.Errorf("second ModerateReview error = %v, want %v", err, ErrReviewAlreadyModerated)
+	}
+
+	restaurant, _ := store.GetRestaurant(ctx, "1")
+	if restaurant.RatingCount != 1 {
+		t.Errorf("rating count = %d, want 1", restaurant.RatingCount)
+	}
+}
+
+// failingRatingStore fails every rating update.
+type failingRatingStore struct {
+	*MemoryRestaurantStore
+}
+
+func (s failingRatingStore) AdjustRating(ctx context.Context, restaurantID string, sum, count int) (*models.Restaurant, error) {
+	return nil, errors.New("rating update failed")
+}
+
+func TestModerateReviewRetriesFailedApproval(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRestaurantStore(models.Restaurant{RestaurantID: "1"})
+	reviews := NewMemoryReviewStore()
+
+	review, err := AddReview(ctx, store, reviews, models.Review{RestaurantID: "1", Rating: 4, Author: "A"})
+	if err != nil {
+		t.Fatalf("AddReview: %v", err)
+	}
+	if _, err := ModerateReview(ctx, failingRatingStore{store}, reviews, "1", review.ReviewID, models.ReviewApproved, ""); err == nil {
+		t.Fatal("ModerateReview succeeded although the rating update failed")
+	}
+
+	stored, err := reviews.GetReview(ctx, "1", review.ReviewID)
+	if err != nil {
+		t.Fatalf("GetReview: %v", err)
+	}
+	if stored.Status != models.ReviewPending {
+		t.Fatalf("review status after the failed approval = %q, want %q", stored.Status, models.ReviewPending)
+	}
+
+	if _, err := ModerateReview(ctx, store, reviews, "1", review.ReviewID, models.ReviewApproved, ""); err != nil {
+		t.Fatalf("retried ModerateReview: %v", err)
+	}
+	restaurant, _ := store.GetRestaurant(ctx, "1")
+	if restaurant.Rating != 4 || restaurant.RatingCount != 1 {
+		t.Errorf("rating = %v from %d ratings, want 4 from 1", restaurant.Rating, restaurant.RatingCount)
+	}
+}
+
+// interleavingStore runs between once, right after the next restaurant read.
+type interleavingStore struct {
+	*MemoryRestaurantStore
+	between func()
+}
+
+func (s *interleavingStore) GetRestaurant(ctx context.Context, restaurantID string) (*models.Restaurant, error) {
+	restaurant, err := s.MemoryRestaurantStore.GetRestaurant(ctx, restaurantID)
+	if between := s.between; between != nil {
+		s.between = nil
+		between()
+	}
+	return restaurant, err
+}
+
+func TestEditKeepsRatingChangedMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryRestaurantStore(models.Restaurant{RestaurantID: "1", Name: "Old"})
+	reviews := NewMemoryReviewStore()
+
+	approve := func(rating int) models.Review {
+		review, err := AddReview(ctx, memory, reviews, models.Review{RestaurantID: "1", Rating: rating, Author: "A"})
+		if err != nil {
+			t.Fatalf("AddReview: %v", err)
+		}
+		if _, err := ModerateReview(ctx, memory, reviews, "1", review.ReviewID, models.ReviewApproved, ""); err != nil {
+			t.Fatalf("ModerateReview: %v", err)
+		}
+		return review
+	}
+	first := approve(2)
+
+	// One rating is removed and another added while the edit is under way,
+	// which leaves the count as the edit read it
+	store := &interleavingStore{MemoryRestaurantStore: memory, between: func() {
+		if err := DeleteReview(ctx, memory, reviews, "1", first.ReviewID); err != nil {
+			t.Fatalf("DeleteReview: %v", err)
+		}
+		approve(5)
+	}}
+	if err := EditRestaurant(ctx, store, models.Restaurant{RestaurantID: "1", Name: "New"}); err != nil {
+		t.Fatalf("EditRestaurant: %v", err)
+	}
+
+	restaurant, _ := memory.GetRestaurant(ctx, "1")
+	if restaurant.Name != "New" {
+		t.Errorf("name = %q, want %q", restaurant.Name, "New")
+	}
+	if restaurant.Rating != 5 || restaurant.RatingCount != 1 {
+		t.Errorf("rating = %v from %d ratings, want 5 from 1", restaurant.Rating, restaurant.RatingCount)
+	}
+}
+
+func TestRemoveRestaurantDeletesReviews(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRestaurantStore(models.Restaurant{RestaurantID: "1"}, models.Restaurant{RestaurantID: "2"})
+	reviews := NewMemoryReviewStore()
+
+	for _, id := range []string{"1", "1", "2"} {
+		if _, err := AddReview(ctx, store, reviews, models.Review{RestaurantID: id, Rating: 3, Author: "A"}); err != nil {
+			t.Fatalf("AddReview: %v", err)
+		}
+	}
+	if err := RemoveRestaurant(ctx, store, reviews, "1"); err != nil {
+		t.Fatalf("RemoveRestaurant: %v", err)
+	}
+
+	pending, _, err := reviews.ListPendingReviews(ctx, 10, "")
+	if err != nil {
+		t.Fatalf("ListPendingReviews: %v", err)
+	}
+	if len(pending) != 1 || pending[0].RestaurantID != "2" {
+		t.Errorf("pending reviews = %v, want only the review of restaurant 2", pending)
+	}
+}
@@ -0,0 +1,459 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"server/models"
+)
+
+// geoCellSize is the side of a geo index cell in degrees, roughly 11 km of latitude.
+const geoCellSize = 0.1
+
+// lngCells is the number of geo cells around the globe at geoCellSize.
+const lngCells = 3600
+
+// maxGeoCells caps how many cells a radius lookup visits before it falls back
+// to every restaurant with coordinates.
+const maxGeoCells = 10000
+
+type idSet map[string]struct{}
+
+func (s idSet) add(id string) {
+	s[id] = struct{}{}
+}
+
+func (s idSet) addAll(other idSet) {
+	for id := range other {
+		s.add(id)
+	}
+}
+
+type geoCell struct {
+	lat int
+	lng int
+}
+
+func cellIndex(degrees float64) int {
+	return int(math.Floor(degrees / geoCellSize))
+}
+
+// wrapLngCell maps a longitude cell index into [-180°, 180°), so that cells
+// past the antimeridian are the ones on the other side of it.
+func wrapLngCell(lng int) int {
+	return ((lng+lngCells/2)%lngCells+lngCells)%lngCells - lngCells/2
+}
+
+func cellOf(loc models.Location) geoCell {
+	return geoCell{
+		lat: cellIndex(loc.Latitude),
+		lng: wrapLngCell(cellIndex(loc.Longitude)),
+	}
+}
+
+// IndexedRestaurantStore serves restaurant reads from an in-memory inverted
+// index built from another store. Writes go to the underlying store first and
+// then update the index, so searches never touch the store. Single lookups by
+// ID still read the underlying store, since admin edits build on them.
+//
+// Each replica keeps its own index; Refresh rebuilds it from the store to pick
+// up writes made through other replicas. Until the first Refresh succeeds,
+// queries are passed on to the underlying store.
+type IndexedRestaurantStore struct {
+	store RestaurantStore
+
+	refreshMu sync.Mutex // one Refresh at a time
+
+	mu    sync.RWMutex
+	ready bool // built by a successful Refresh
+	// written holds the writes made while a Refresh reads the store, with nil
+	// for deletions, so that they can be applied over the rebuilt index. It
+	// is nil when no Refresh is running.
+	written     map[string]*models.Restaurant
+	restaurants map[string]models.Restaurant
+	tokens      map[string]idSet // folded word from name, cuisine or address
+	cuisines    map[string]idSet // lowercased cuisine
+	kosher      map[bool]idSet
+	cities      map[string]idSet // folded city
+	countries   map[string]idSet // folded country
+	dietary     map[string]idSet // dietary tag, including kosher from IsKosher
+	prices      map[int]idSet    // known price level
+	cells       map[geoCell]idSet
+	located     idSet // restaurants with coordinates
+	prefixes    *prefixIndex
+}
+
+func NewIndexedRestaurantStore(store RestaurantStore) *IndexedRestaurantStore {
+	s := &IndexedRestaurantStore{store: store}
+	s.reset()
+	return s
+}
+
+func (s *IndexedRestaurantStore) reset() {
+	s.restaurants = make(map[string]models.Restaurant)
+	s.tokens = make(map[string]idSet)
+	s.cuisines = make(map[string]idSet)
+	s.kosher = make(map[bool]idSet)
+	s.cities = make(map[string]idSet)
+	s.countries = make(map[string]idSet)
+	s.dietary = make(map[string]idSet)
+	s.prices = make(map[int]idSet)
+	s.cells = make(map[geoCell]idSet)
+	s.located = make(idSet)
+	s.prefixes = newPrefixIndex()
+}
+
+// Refresh rebuilds the index from the underlying store and returns the number
+// of restaurants indexed. Writes made through this store while the store is
+// read are applied again afterwards, since the read may predate them.
+func (s *IndexedRestaurantStore) Refresh(ctx context.Context) (int, error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.Lock()
+	s.written = make(map[string]*models.Restaurant)
+	s.mu.Unlock()
+
+	restaurants, err := s.store.ListRestaurants(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	written := s.written
+	s.written = nil
+	if err != nil {
+		return 0, err
+	}
+
+	s.reset()
+	for _, r := range restaurants {
+		s.index(r)
+	}
+	for id, r := range written {
+		s.unindex(id)
+		if r != nil {
+			s.index(*r)
+		}
+	}
+	s.prefixes.rebuild()
+	s.ready = true
+	return len(s.restaurants), nil
+}
+
+func addPosting[K comparable](postings map[K]idSet, key K, id string) {
+	set, ok := postings[key]
+	if !ok {
+		set = make(idSet)
+		postings[key] = set
+	}
+	set.add(id)
+}
+
+func removePosting[K comparable](postings map[K]idSet, key K, id string) {
+	set, ok := postings[key]
+	if !ok {
+		return
+	}
+	delete(set, id)
+	if len(set) == 0 {
+		delete(postings, key)
+	}
+}
+
+// indexKeys are the posting list keys a restaurant is filed under.
+type indexKeys struct {
+	words   []string
+	cuisine string
+	city    string
+	country string
+	dietary []string
+	cell    *geoCell
+}
+
+func indexKeysFor(r models.Restaurant) indexKeys {
+	r.NormalizeDietaryTags()
+	keys := indexKeys{
+		words:   append(append(tokenize(r.Name), tokenize(r.CuisineType)...), tokenize(r.Address)...),
+		cuisine: cuisineKey(r.CuisineType),
+		city:    placeKey(restaurantCity(r)),
+		country: placeKey(restaurantCountry(r)),
+		dietary: r.DietaryTags,
+	}
+	if r.Location != nil {
+		cell := cellOf(*r.Location)
+		keys.cell = &cell
+	}
+	return keys
+}
+
+// index adds the restaurant to the index. The caller holds the write lock.
+func (s *IndexedRestaurantStore) index(r models.Restaurant) {
+	id := r.RestaurantID
+	keys := indexKeysFor(r)
+
+	s.restaurants[id] = r
+	for _, word := range keys.words {
+		addPosting(s.tokens, word, id)
+	}
+	if keys.cuisine != "" {
+		addPosting(s.cuisines, keys.cuisine, id)
+	}
+	addPosting(s.kosher, r.IsKosher, id)
+	if keys.city != "" {
+		addPosting(s.cities, keys.city, id)
+	}
+	if keys.country != "" {
+		addPosting(s.countries, keys.country, id)
+	}
+	for _, tag := range keys.dietary {
+		addPosting(s.dietary, tag, id)
+	}
+	if r.PriceLevel != 0 {
+		addPosting(s.prices, r.PriceLevel, id)
+	}
+	if keys.cell != nil {
+		addPosting(s.cells, *keys.cell, id)
+		s.located.add(id)
+	}
+	s.prefixes.add(r)
+}
+
+// unindex removes the restaurant from the index. The caller holds the write lock.
+func (s *IndexedRestaurantStore) unindex(id string) {
+	r, ok := s.restaurants[id]
+	if !ok {
+		return
+	}
+	keys := indexKeysFor(r)
+
+	delete(s.restaurants, id)
+	for _, word := range keys.words {
+		removePosting(s.tokens, word, id)
+	}
+	removePosting(s.cuisines, keys.cuisine, id)
+	removePosting(s.kosher, r.IsKosher, id)
+	removePosting(s.cities, keys.city, id)
+	removePosting(s.countries, keys.country, id)
+	for _, tag := range keys.dietary {
+		removePosting(s.dietary, tag, id)
+	}
+	removePosting(s.prices, r.PriceLevel, id)
+	if keys.cell != nil {
+		removePosting(s.cells, *keys.cell, id)
+	}
+	delete(s.located, id)
+	s.prefixes.remove(r)
+}
+
+func (s *IndexedRestaurantStore) ListRestaurants(ctx context.Context) ([]models.Restaurant, error) {
+	return s.QueryRestaurants(ctx, RestaurantQuery{})
+}
+
+// QueryRestaurants intersects the posting lists of every indexed filter in
+// the query. The result may include restaurants that the full search filters
+// later reject, but never leaves out one that would match.
+func (s *IndexedRestaurantStore) QueryRestaurants(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error) {
+	s.mu.RLock()
+	if !s.ready {
+		// Cold start: serve from the store's own indexes until the first build
+		s.mu.RUnlock()
+		return s.store.QueryRestaurants(ctx, query)
+	}
+	defer s.mu.RUnlock()
+
+	var candidates idSet
+	restrict := func(set idSet) {
+		if candidates == nil {
+			candidates = make(idSet, len(set))
+			candidates.addAll(set)
+			return
+		}
+		for id := range candidates {
+			if _, ok := set[id]; !ok {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	if len(query.Cuisines) > 0 {
+		matched := make(idSet)
+		for _, cuisine := range query.Cuisines {
+			matched.addAll(s.cuisines[cuisineKey(cuisine)])
+		}
+		restrict(matched)
+	}
+	if query.IsKosher != nil {
+		restrict(s.kosher[*query.IsKosher])
+	}
+	if len(query.Cities) > 0 {
+		matched := make(idSet)
+		for _, city := range query.Cities {
+			matched.addAll(s.cities[placeKey(city)])
+		}
+		restrict(matched)
+	}
+	if len(query.Countries) > 0 {
+		matched := make(idSet)
+		for _, country := range query.Countries {
+			matched.addAll(s.countries[placeKey(country)])
+		}
+		restrict(matched)
+	}
+	for _, tag := range query.DietaryTags {
+		restrict(s.dietary[strings.ToLower(strings.TrimSpace(tag))])
+	}
+	if query.MinPrice > 0 || query.MaxPrice > 0 {
+		matched := make(idSet)
+		for level, ids := range s.prices {
+			if inPriceRange(level, query.MinPrice, query.MaxPrice) {
+				matched.addAll(ids)
+			}
+		}
+		restrict(matched)
+	}
+	for _, token := range tokenize(query.Text) {
+		restrict(s.matchToken(token))
+	}
+	if query.Near != nil {
+		restrict(s.nearby(*query.Near, query.RadiusKm))
+	}
+
+	var restaurants []models.Restaurant
+	if candidates == nil {
+		restaurants = make([]models.Restaurant, 0, len(s.restaurants))
+		for _, r := range s.restaurants {
+			restaurants = append(restaurants, r)
+		}
+	} else {
+		restaurants = make([]models.Restaurant, 0, len(candidates))
+		for id := range candidates {
+			restaurants = append(restaurants, s.restaurants[id])
+		}
+	}
+
+	// Keep the order stable between calls
+	sort.Slice(restaurants, func(i, j int) bool {
+		return restaurants[i].RestaurantID < restaurants[j].RestaurantID
+	})
+
+	return restaurants, nil
+}
+
+// matchToken returns the restaurants with a word that the query token
+// matches, using the same typo tolerance as free-text scoring.
+func (s *IndexedRestaurantStore) matchToken(token string) idSet {
+	matched := make(idSet)
+	for word, ids := range s.tokens {
+		if tokenScore(token, word) == 0 {
+			continue
+		}
+		matched.addAll(ids)
+	}
+	return matched
+}
+
+// nearby returns the restaurants in the cells overlapping the bounding box of
+// the radius, or every restaurant with coordinates if there is no radius. A
+// box crossing the antimeridian wraps around to the other side; one crossing
+// a pole covers every longitude, so it falls back to every restaurant.
+func (s *IndexedRestaurantStore) nearby(center models.Location, radiusKm float64) idSet {
+	if radiusKm <= 0 {
+		return s.located
+	}
+
+	// The box around the circle: the latitude range is the angular radius; the
+	// longitude range is widest at the latitude where the circle touches its
+	// meridians, where it is asin(sin d / cos φ) on either side
+	angle := radiusKm / earthRadiusKm
+	latDelta := angle * 180 / math.Pi
+	sinRatio := math.Sin(angle) / math.Cos(center.Latitude*math.Pi/180)
+	if sinRatio >= 1 || center.Latitude+latDelta >= 90 || center.Latitude-latDelta <= -90 {
+		// The circle contains a pole, so it spans every longitude
+		return s.located
+	}
+	lngDelta := math.Asin(sinRatio) * 180 / math.Pi
+
+	minLat := cellIndex(center.Latitude - latDelta)
+	maxLat := cellIndex(center.Latitude + latDelta)
+	minLng := cellIndex(center.Longitude - lngDelta)
+	maxLng := cellIndex(center.Longitude + lngDelta)
+	if maxLng-minLng+1 >= lngCells || (maxLat-minLat+1)*(maxLng-minLng+1) > maxGeoCells {
+		return s.located
+	}
+
+	matched := make(idSet)
+	for lat := minLat; lat <= maxLat; lat++ {
+		for lng := minLng; lng <= maxLng; lng++ {
+			matched.addAll(s.cells[geoCell{lat: lat, lng: wrapLngCell(lng)}])
+		}
+	}
+	return matched
+}
+
+// Suggest completes the prefix to restaurant names, cuisines and cities. It
+// returns nothing until the index is first built.
+func (s *IndexedRestaurantStore) Suggest(prefix string, limit int) []Suggestion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.prefixes.suggest(prefix, limit)
+}
+
+func (s *IndexedRestaurantStore) GetRestaurant(ctx context.Context, restaurantID string) (*models.Restaurant, error) {
+	return s.store.GetRestaurant(ctx, restaurantID)
+}
+
+func (s *IndexedRestaurantStore) PutRestaurant(ctx context.Context, restaurant models.Restaurant) error {
+	if err := s.store.PutRestaurant(ctx, restaurant); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unindex(restaurant.RestaurantID)
+	s.index(restaurant)
+	s.prefixes.rebuild()
+	if s.written != nil {
+		s.written[restaurant.RestaurantID] = &restaurant
+	}
+	return nil
+}
+
+func (s *IndexedRestaurantStore) AdjustRating(ctx context.Context, restaurantID string, sum, count int) (*models.Restaurant, error) {
+	restaurant, err := s.store.AdjustRating(ctx, restaurantID, sum, count)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unindex(restaurantID)
+	s.index(*restaurant)
+	s.prefixes.rebuild()
+	if s.written != nil {
+		updated := *restaurant
+		s.written[restaurantID] = &updated
+	}
+	return restaurant, nil
+}
+
+func (s *IndexedRestaurantStore) DeleteRestaurant(ctx context.Context, restaurantID string) error {
+	if err := s.store.DeleteRestaurant(ctx, restaurantID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unindex(restaurantID)
+	s.prefixes.rebuild()
+	if s.written != nil {
+		s.written[restaurantID] = nil
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"server/models"
+)
+
+// seedRestaurants loads the restaurants the server is seeded with, plus a
+// few around the antimeridian and at high latitude.
+func seedRestaurants(t *testing.T) []models.Restaurant {
+	t.Helper()
+
+	data, err := os.ReadFile("../data/restaurants_data.json")
+	if err != nil {
+		t.Fatalf("reading seed data: %v", err)
+	}
+	var restaurants []models.Restaurant
+	if err := json.Unmarshal(data, &restaurants); err != nil {
+		t.Fatalf("decoding seed data: %v", err)
+	}
+
+	restaurants = append(restaurants,
+		models.Restaurant{RestaurantID: "east", Name: "Dateline East", CuisineType: "Seafood", Address: "1 Harbour Rd, Suva, Fiji", Location: &models.Location{Latitude: -18, Longitude: 179.99}},
+		models.Restaurant{RestaurantID: "west", Name: "Dateline West", CuisineType: "Seafood", Address: "2 Harbour Rd, Apia, Samoa", Location: &models.Location{Latitude: -18, Longitude: -179.99}},
+		models.Restaurant{RestaurantID: "north", Name: "Polar Station", CuisineType: "Nordic", Address: "3 Ice Rd, Longyearbyen, Norway", Location: &models.Location{Latitude: 80.16, Longitude: 10.405}},
+	)
+	for i := range restaurants {
+		restaurants[i].FillAddressParts()
+		restaurants[i].NormalizeDietaryTags()
+	}
+	return restaurants
+}
+
+func TestIndexedQueryCoversFilters(t *testing.T) {
+	restaurants := seedRestaurants(t)
+	index := NewIndexedRestaurantStore(NewMemoryRestaurantStore(restaurants...))
+	if _, err := index.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Fixed, so that the seeded kosher certificates do not expire under the test
+	now := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	newYork := &models.Location{Latitude: 40.7128, Longitude: -74.0060}
+	dateline := &models.Location{Latitude: -18, Longitude: -179.999}
+
+	tests := []struct {
+		name    string
+		filters SearchFilters
+	}{
+		{name: "no filters", filters: SearchFilters{}},
+		{name: "cuisine", filters: SearchFilters{Cuisines: []string{"italian", "JAPANESE"}}},
+		{name: "kosher", filters: SearchFilters{IsKosher: "true"}},
+		{name: "not kosher", filters: SearchFilters{IsKosher: "false"}},
+		{name: "city", filters: SearchFilters{Cities: []string{"new york"}}},
+		{name: "country", filters: SearchFilters{Countries: []string{"usa"}}},
+		{name: "dietary tags", filters: SearchFilters{DietaryTags: []string{"vegan", "kosher"}}},
+		{name: "price range", filters: SearchFilters{MinPrice: 2, MaxPrice: 3}},
+		{name: "text", filters: SearchFilters{Query: "japanese"}},
+		{name: "text with a typo", filters: SearchFilters{Query: "japanse"}},
+		{name: "text prefix", filters: SearchFilters{Query: "resta"}},
+		{name: "text over several fields", filters: SearchFilters{Query: "vegan san diego"}},
+		{name: "near", filters: SearchFilters{Near: newYork, RadiusKm: 10}},
+		{name: "near without radius", filters: SearchFilters{Near: newYork}},
+		{name: "near the antimeridian", filters: SearchFilters{Near: dateline, RadiusKm: 50}},
+		// 199.8 km away, beyond the longitude range a flat box would cover
+		{name: "near at high latitude", filters: SearchFilters{Near: &models.Location{Latitude: 80, Longitude: 0}, RadiusKm: 200}},
+		{name: "combined", filters: SearchFilters{Cuisines: []string{"vegan"}, IsKosher: "true", Countries: []string{"USA"}, MinPrice: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filters.IncludeInactive = true
+
+			want := filterRestaurants(restaurants, tt.filters, now)
+			if len(want) == 0 {
+				t.Fatal("filters match no restaurant, so the test proves nothing")
+			}
+
+			candidates, err := index.QueryRestaurants(context.Background(), tt.filters.storeQuery())
+			if err != nil {
+				t.Fatalf("QueryRestaurants: %v", err)
+			}
+			got := make(map[string]bool, len(candidates))
+			for _, r := range candidates {
+				got[r.RestaurantID] = true
+			}
+
+			for _, r := range want {
+				if !got[r.RestaurantID] {
+					t.Errorf("index left out restaurant %s (%s), which matches the filters", r.RestaurantID, r.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrRestaurantNotFound = errors.New("restaurant not found")
+
+// ErrRatingChanged is returned by PutRestaurant when a review changed the
+// stored rating since the restaurant was read. Read it again and retry.
+var ErrRatingChanged = errors.New("restaurant rating changed")
+
+// RestaurantQuery selects restaurants by the filters a store can serve from an index.
+// Empty fields do not restrict the result. A store may ignore fields it cannot
+// serve and return more restaurants than match, but never fewer.
+//
+// Cuisines, Cities and Countries match any of the listed values, case-insensitively;
+// DietaryTags match restaurants with all of the listed tags, and MinPrice and
+// MaxPrice bound the price level when non-zero.
+type RestaurantQuery struct {
+	Cuisines  []string
+	IsKosher  *bool
+	Cities    []string
+	Countries []string
+
+	DietaryTags []string
+	MinPrice    int
+	MaxPrice    int
+	Text        string
+	Near        *models.Location
+	RadiusKm    float64
+}
+
+// RestaurantStore is the persistence layer used by the restaurant services.
+type RestaurantStore interface {
+	ListRestaurants(ctx context.Context) ([]models.Restaurant, error)
+	QueryRestaurants(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error)
+	GetRestaurant(ctx context.Context, restaurantID string) (*models.Restaurant, error)
+	// PutRestaurant stores the restaurant, including the rating it was read
+	// with, and fails with ErrRatingChanged if that rating is out of date.
+	PutRestaurant(ctx context.Context, restaurant models.Restaurant) error
+	DeleteRestaurant(ctx context.Context, restaurantID string) error
+	// AdjustRating atomically adds sum and count to the restaurant's rating
+	// sum and count, and returns the updated restaurant. Negative values take
+	// a rating out again.
+	AdjustRating(ctx context.Context, restaurantID string, sum, count int) (*models.Restaurant, error)
+}
+
+// Global secondary indexes on the restaurants table, defined in infra/main.tf.
+// Index key attributes must be strings, so they are derived from the
+// restaurant on write: the cuisine is lowercased to keep matching
+// case-insensitive. The kosher index is sparse: only kosher restaurants get a
+// kosher key, so it holds just the restaurants kosher searches look for.
+const (
+	cuisineIndexName = "cuisine_key-index"
+	cuisineKeyAttr   = "cuisine_key"
+	kosherIndexName  = "kosher_key-index"
+	kosherKeyAttr    = "kosher_key"
+	kosherKeyValue   = "true"
+)
+
+// Every item is stamped with the item layout version it was written with.
+// BackfillItems rewrites items of older versions once, so the version must
+// be raised whenever the derived attributes change.
+//
+//	1: structured address parts and index keys
+//	2: kosher key only on kosher restaurants
+//	3: rating sum instead of the average rating
+//	4: timezone and location of seeded restaurants stored before they had them
+const (
+	itemVersionAttr = "item_version"
+	itemVersion     = 4
+)
+
+// Rating attributes. Items store the sum and count of the approved review
+// ratings, which reviews change with atomic additions; the average is
+// derived from them on read. Items of version 2 and older store the average
+// as rating instead of the sum.
+const (
+	ratingAttr      = "rating"
+	ratingSumAttr   = "rating_sum"
+	ratingCountAttr = "rating_count"
+)
+
+func cuisineKey(cuisine string) string {
+	return strings.ToLower(strings.TrimSpace(cuisine))
+}
+
+// marshalRestaurantItem converts a restaurant to a DynamoDB item, including the index key attributes.
+func marshalRestaurantItem(restaurant models.Restaurant) (map[string]types.AttributeValue, error) {
+	restaurant.NormalizeRating()
+	item, err := attributevalue.MarshalMap(restaurant)
+	if err != nil {
+		return nil, err
+	}
+	delete(item, ratingAttr)
+
+	// Empty strings are not allowed as index keys, so restaurants without a cuisine stay out of that index
+	if key := cuisineKey(restaurant.CuisineType); key != "" {
+		item[cuisineKeyAttr] = &types.AttributeValueMemberS{Value: key}
+	}
+	if restaurant.IsKosher {
+		item[kosherKeyAttr] = &types.AttributeValueMemberS{Value: kosherKeyValue}
+	}
+	item[itemVersionAttr] = &types.AttributeValueMemberN{Value: strconv.Itoa(itemVersion)}
+
+	return item, nil
+}
+
+// unmarshalRestaurants converts DynamoDB items to restaurants, deriving their average rating.
+func unmarshalRestaurants(items []map[string]types.AttributeValue) ([]models.Restaurant, error) {
+	var restaurants []models.Restaurant
+	if err := attributevalue.UnmarshalListOfMaps(items, &restaurants); err != nil {
+		return nil, err
+	}
+	for i := range restaurants {
+		restaurants[i].NormalizeRating()
+	}
+	return restaurants, nil
+}
+
+func unmarshalRestaurant(item map[string]types.AttributeValue) (*models.Restaurant, error) {
+	var restaurant models.Restaurant
+	if err := attributevalue.UnmarshalMap(item, &restaurant); err != nil {
+		return nil, err
+	}
+	restaurant.NormalizeRating()
+	return &restaurant, nil
+}
+
+// ratingUnchanged returns a condition that holds if the item does not exist
+// yet or still has the restaurant's rating sum and count, so that a write
+// cannot overwrite a rating added or removed since the read. Both have to
+// match: a removal and an addition since the read may leave the count as it
+// was. Items that were never rated since ratings were summed have no sum.
+func ratingUnchanged(restaurant models.Restaurant, names map[string]string, values map[string]types.AttributeValue) string {
+	names["#rid"] = "restaurant_id"
+	names["#rs"] = ratingSumAttr
+	names["#rc"] = ratingCountAttr
+	values[":rs"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(restaurant.RatingSum, 'f', -1, 64)}
+	values[":rc"] = &types.AttributeValueMemberN{Value: strconv.Itoa(restaurant.RatingCount)}
+
+	count := "#rc = :rc"
+	if restaurant.RatingCount == 0 {
+		// Restaurants without ratings are stored without a count
+		count = "(attribute_not_exists(#rc) OR #rc = :rc)"
+	}
+	return "attribute_not_exists(#rid) OR attribute_not_exists(#rs) OR (#rs = :rs AND " + count + ")"
+}
+
+// DynamoRestaurantStore keeps restaurants in a DynamoDB table.
+type DynamoRestaurantStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewDynamoRestaurantStore(client *dynamodb.Client, tableName string) *DynamoRestaurantStore {
+	return &DynamoRestaurantStore{client: client, tableName: tableName}
+}
+
+func (s *DynamoRestaurantStore) ListRestaurants(ctx context.Context) ([]models.Restaurant, error) {
+	var restaurants []models.Restaurant
+
+	// Initialize ScanInput
+	input := &dynamodb.ScanInput{
+		TableName: &s.tableName,
+	}
+
+	// Handle pagination
+	for {
+		result, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		batch, err := unmarshalRestaurants(result.Items)
+		if err != nil {
+			return nil, err
+		}
+		restaurants = append(restaurants, batch...)
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return restaurants, nil
+}
+
+// QueryRestaurants serves the cuisine filter from the cuisine index, with one
+// query per cuisine, and is_kosher=true from the kosher index when no cuisine
+// is given. Without any indexed filter it falls back to scanning the whole
+// table; is_kosher=false cannot be served from the sparse index either.
+func (s *DynamoRestaurantStore) QueryRestaurants(ctx context.Context, query RestaurantQuery) ([]models.Restaurant, error) {
+	switch {
+	case len(query.Cuisines) > 0:
+		var restaurants []models.Restaurant
+		seen := make(map[string]bool)
+		for _, cuisine := range query.Cuisines {
+			key := cuisineKey(cuisine)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			input := &dynamodb.QueryInput{
+				TableName:              &s.tableName,
+				IndexName:              aws.String(cuisineIndexName),
+				KeyConditionExpression: aws.String("#ck = :cuisine"),
+				ExpressionAttributeNames: map[string]string{
+					"#ck": cuisineKeyAttr,
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":cuisine": &types.AttributeValueMemberS{Value: key},
+				},
+			}
+			if query.IsKosher != nil && *query.IsKosher {
+				input.FilterExpression = aws.String("#kk = :kosher")
+				input.ExpressionAttributeNames["#kk"] = kosherKeyAttr
+				input.ExpressionAttributeValues[":kosher"] = &types.AttributeValueMemberS{Value: kosherKeyValue}
+			}
+
+			batch, err := s.queryAll(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			restaurants = append(restaurants, batch...)
+		}
+		return restaurants, nil
+	case query.IsKosher != nil && *query.IsKosher:
+		return s.queryAll(ctx, &dynamodb.QueryInput{
+			TableName:              &s.tableName,
+			IndexName:              aws.String(kosherIndexName),
+			KeyConditionExpression: aws.String("#kk = :kosher"),
+			ExpressionAttributeNames: map[string]string{
+				"#kk": kosherKeyAttr,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":kosher": &types.AttributeValueMemberS{Value: kosherKeyValue},
+			},
+		})
+	default:
+		return s.ListRestaurants(ctx)
+	}
+}
+
+// queryAll runs the query and collects every page of results.
+func (s *DynamoRestaurantStore) queryAll(ctx context.Context, input *dynamodb.QueryInput) ([]models.Restaurant, error) {
+	var restaurants []models.Restaurant
+
+	// Handle pagination
+	for {
+		result, err := s.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		batch, err := unmarshalRestaurants(result.Items)
+		if err != nil {
+			return nil, err
+		}
+		restaurants = append(restaurants, batch...)
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return restaurants, nil
+}
+
+// BackfillItems rewrites items written with an older item version, so that
+// the indexes cover every restaurant and the address parts are parsed from
+// the one-line address. Missing timezones and locations are taken from the
+// seed restaurant with the same ID. Each item is rewritten once, and only if
+// it was not changed or deleted in the meantime, so that concurrent admin
+// edits and deletions are kept.
+func (s *DynamoRestaurantStore) BackfillItems(ctx context.Context, seed []models.Restaurant) (int, error) {
+	seeded := make(map[string]models.Restaurant, len(seed))
+	for _, r := range seed {
+		seeded[r.RestaurantID] = r
+	}
+
+	outdated := "attribute_not_exists(#v) OR #v < :v"
+	names := map[string]string{"#v": itemVersionAttr}
+	values := map[string]types.AttributeValue{
+		":v": &types.AttributeValueMemberN{Value: strconv.Itoa(itemVersion)},
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 &s.tableName,
+		FilterExpression:          aws.String(outdated),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	updated := 0
+	for {
+		result, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return updated, err
+		}
+
+		batch, err := unmarshalRestaurants(result.Items)
+		if err != nil {
+			return updated, err
+		}
+		for _, restaurant := range batch {
+			restaurant.FillAddressParts()
+			if original, ok := seeded[restaurant.RestaurantID]; ok {
+				if restaurant.Timezone == "" {
+					restaurant.Timezone = original.Timezone
+				}
+				if restaurant.Location == nil {
+					restaurant.Location = original.Location
+				}
+			}
+			if restaurant.Timezone == "" {
+				log.Printf("Restaurant %s has no timezone; set one with an admin edit", restaurant.RestaurantID)
+			}
+			item, err := marshalRestaurantItem(restaurant)
+			if err != nil {
+				return updated, err
+			}
+
+			putNames := map[string]string{"#v": itemVersionAttr}
+			putValues := map[string]types.AttributeValue{":v": values[":v"]}
+			putNames["#rid"] = "restaurant_id"
+			condition := "attribute_exists(#rid) AND (" + outdated + ") AND (" + ratingUnchanged(restaurant, putNames, putValues) + ")"
+			_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:                 &s.tableName,
+				Item:                      item,
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeNames:  putNames,
+				ExpressionAttributeValues: putValues,
+			})
+			var changed *types.ConditionalCheckFailedException
+			if errors.As(err, &changed) {
+				// Deleted, or rewritten by an admin edit, a review or another
+				// replica since the scan: deleted items stay deleted, edited
+				// ones are current and rated ones are picked up by the next
+				// backfill
+				continue
+			}
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	return updated, nil
+}
+
+func (s *DynamoRestaurantStore) GetRestaurant(ctx context.Context, restaurantID string) (*models.Restaurant, error) {
+	// Prepare the key for querying the item. The read is consistent, so that
+	// a restaurant read to be changed has the latest rating.
+	input := &dynamodb.GetItemInput{
+		TableName: &s.tableName,
+		Key: map[string]types.AttributeValue{
+			"restaurant_id": &types.AttributeValueMemberS{Value: restaurantID},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	// Fetch the item from DynamoDB
+	result, err := s.client.GetItem(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if the item exists
+	if result.Item == nil {
+		return nil, ErrRestaurantNotFound
+	}
+
+	// Unmarshal the item into a Restaurant struct
+	return unmarshalRestaurant(result.Item)
+}
+
+func (s *DynamoRestaurantStore) PutRestaurant(ctx context.Context, restaurant models.Restaurant) error {
+	// Convert restaurant to DynamoDB item
+	item, err := marshalRestaurantItem(restaurant)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 &s.tableName,
+		Item:                      item,
+		ConditionExpression:       aws.String(ratingUnchanged(restaurant, names, values)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	var changed *types.ConditionalCheckFailedException
+	if errors.As(err, &changed) {
+		return ErrRatingChanged
+	}
+	return err
+}
+
+// AdjustRating adds to the rating sum and count in one atomic update, so that
+// reviews approved at the same time on different replicas are all counted.
+func (s *DynamoRestaurantStore) AdjustRating(ctx context.Context, restaurantID string, sum, count int) (*models.Restaurant, error) {
+	updatedAt, err := attributevalue.Marshal(time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: &s.tableName,
+			Key: map[string]types.AttributeValue{
+				"restaurant_id": &types.AttributeValueMemberS{Value: restaurantID},
+			},
+			UpdateExpression:    aws.String("ADD #rs :sum, #rc :count SET #ua = :now"),
+			ConditionExpression: aws.String("attribute_exists(#rs)"),
+			ExpressionAttributeNames: map[string]string{
+				"#rs": ratingSumAttr,
+				"#rc": ratingCountAttr,
+				"#ua": "updated_at",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sum":   &types.AttributeValueMemberN{Value: strconv.Itoa(sum)},
+				":count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)},
+				":now":   updatedAt,
+			},
+			ReturnValues: types.ReturnValueAllNew,
+		})
+		var missing *types.ConditionalCheckFailedException
+		if errors.As(err, &missing) {
+			if attempt > 0 {
+				// Deleted since the migration
+				return nil, ErrRestaurantNotFound
+			}
+			// The restaurant does not exist, or was stored before ratings were summed
+			if err := s.migrateRatingSum(ctx, restaurantID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return unmarshalRestaurant(result.Attributes)
+	}
+}
+
+// migrateRatingSum stores the rating sum of an item written before ratings
+// were summed, derived from its average rating, unless it has one by now.
+func (s *DynamoRestaurantStore) migrateRatingSum(ctx context.Context, restaurantID string) error {
+	restaurant, err := s.GetRestaurant(ctx, restaurantID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &s.tableName,
+		Key: map[string]types.AttributeValue{
+			"restaurant_id": &types.AttributeValueMemberS{Value: restaurantID},
+		},
+		UpdateExpression:    aws.String("SET #rs = :sum REMOVE #r"),
+		ConditionExpression: aws.String("attribute_exists(#rid) AND attribute_not_exists(#rs)"),
+		ExpressionAttributeNames: map[string]string{
+			"#rid": "restaurant_id",
+			"#rs":  ratingSumAttr,
+			"#r":   ratingAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sum": &types.AttributeValueMemberN{Value: strconv.FormatFloat(restaurant.RatingSum, 'f', -1, 64)},
+		},
+	})
+	var migrated *types.ConditionalCheckFailedException
+	if errors.As(err, &migrated) {
+		// Migrated by another replica or the backfill, or deleted, since the read
+		return nil
+	}
+	return err
+}
+
+func (s *DynamoRestaurantStore) DeleteRestaurant(ctx context.Context, restaurantID string) error {
+	// Build key for deletion
+	key, err := attributevalue.MarshalMap(map[string]string{
+		"restaurant_id": restaurantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &s.tableName,
+		Key:       key,
+	})
+	return err
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"server/models"
+)
+
+const (
+	DefaultSuggestLimit = 10
+	MaxSuggestLimit     = 50
+)
+
+// Suggestion types.
+const (
+	SuggestName    = "name"
+	SuggestCuisine = "cuisine"
+	SuggestCity    = "city"
+)
+
+// Suggestion is an autocomplete match. Count is the number of restaurants
+// with that name, cuisine or city.
+type Suggestion struct {
+	Text  string `json:"text"`
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// Suggester completes a typed prefix to restaurant names, cuisines and cities.
+type Suggester interface {
+	Suggest(prefix string, limit int) []Suggestion
+}
+
+// suggestTerm identifies a name, cuisine or city regardless of case and diacritics.
+type suggestTerm struct {
+	kind string
+	key  string
+}
+
+// prefixEntry is one searchable form of a term: the whole term, or the term
+// from one of its later words on, so "Pizza" also completes "Joe's Pizza".
+type prefixEntry struct {
+	text      string
+	term      suggestTerm
+	wordStart bool
+}
+
+// prefixIndex answers prefix lookups with a binary search over the sorted
+// entries of every term. Terms are counted so that one shared by several
+// restaurants stays until the last of them is removed.
+type prefixIndex struct {
+	labels  map[suggestTerm]string
+	counts  map[suggestTerm]int
+	entries []prefixEntry
+	dirty   bool
+}
+
+func newPrefixIndex() *prefixIndex {
+	return &prefixIndex{
+		labels: make(map[suggestTerm]string),
+		counts: make(map[suggestTerm]int),
+	}
+}
+
+// normalizePrefix folds the text and collapses whitespace, so lookups ignore case, accents and spacing.
+func normalizePrefix(s string) string {
+	return strings.Join(strings.Fields(foldText(s)), " ")
+}
+
+// suggestTerms returns the terms a restaurant contributes, keyed by term with the label to show.
+// Permanently closed restaurants are not suggested.
+func suggestTerms(r models.Restaurant) map[suggestTerm]string {
+	terms := make(map[suggestTerm]string)
+	if r.Status == models.StatusPermanentlyClosed {
+		return terms
+	}
+
+	for kind, label := range map[string]string{
+		SuggestName:    r.Name,
+		SuggestCuisine: r.CuisineType,
+		SuggestCity:    restaurantCity(r),
+	} {
+		label = strings.TrimSpace(label)
+		if key := normalizePrefix(label); key != "" {
+			terms[suggestTerm{kind: kind, key: key}] = label
+		}
+	}
+	return terms
+}
+
+func (p *prefixIndex) add(r models.Restaurant) {
+	for term, label := range suggestTerms(r) {
+		if p.counts[term] == 0 {
+			p.labels[term] = label
+			p.dirty = true
+		}
+		p.counts[term]++
+	}
+}
+
+func (p *prefixIndex) remove(r models.Restaurant) {
+	for term := range suggestTerms(r) {
+		if p.counts[term] == 0 {
+			continue
+		}
+		p.counts[term]--
+		if p.counts[term] == 0 {
+			delete(p.counts, term)
+			delete(p.labels, term)
+			p.dirty = true
+		}
+	}
+}
+
+// rebuild re-sorts the entries after terms were added or removed.
+func (p *prefixIndex) rebuild() {
+	if !p.dirty {
+		return
+	}
+
+	p.entries = p.entries[:0]
+	for term := range p.labels {
+		words := strings.Split(term.key, " ")
+		for i := range words {
+			p.entries = append(p.entries, prefixEntry{
+				text:      strings.Join(words[i:], " "),
+				term:      term,
+				wordStart: i > 0,
+			})
+		}
+	}
+	sort.Slice(p.entries, func(i, j int) bool {
+		return p.entries[i].text < p.entries[j].text
+	})
+	p.dirty = false
+}
+
+// suggest returns up to limit terms with a word starting with the prefix.
+// Terms that start with the prefix come first, then more common terms.
+func (p *prefixIndex) suggest(prefix string, limit int) []Suggestion {
+	prefix = normalizePrefix(prefix)
+	if prefix == "" {
+		return []Suggestion{}
+	}
+
+	// A term matching at its start beats the same term matching at a later word
+	matches := make(map[suggestTerm]bool)
+	start := sort.Search(len(p.entries), func(i int) bool {
+		return p.entries[i].text >= prefix
+	})
+	for _, entry := range p.entries[start:] {
+		if !strings.HasPrefix(entry.text, prefix) {
+			break
+		}
+		wordStart, seen := matches[entry.term]
+		matches[entry.term] = entry.wordStart && (!seen || wordStart)
+	}
+
+	type match struct {
+		suggestion Suggestion
+		wordStart  bool
+	}
+	ranked := make([]match, 0, len(matches))
+	for term, wordStart := range matches {
+		ranked = append(ranked, match{
+			suggestion: Suggestion{Text: p.labels[term], Type: term.kind, Count: p.counts[term]},
+			wordStart:  wordStart,
+		})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.wordStart != b.wordStart {
+			return !a.wordStart
+		}
+		if a.suggestion.Count != b.suggestion.Count {
+			return a.suggestion.Count > b.suggestion.Count
+		}
+		if a.suggestion.Text != b.suggestion.Text {
+			return a.suggestion.Text < b.suggestion.Text
+		}
+		return a.suggestion.Type < b.suggestion.Type
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	suggestions := make([]Suggestion, len(ranked))
+	for i, m := range ranked {
+		suggestions[i] = m.suggestion
+	}
+	return suggestions
+}
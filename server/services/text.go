@@ -0,0 +1,158 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"server/models"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Field weights for free-text matching: a hit in the name counts more than
+// one in the cuisine, which counts more than one in the address.
+const (
+	nameWeight    = 3.0
+	cuisineWeight = 2.0
+	addressWeight = 1.0
+)
+
+// foldText lowercases the text and strips diacritics, so "Café" matches "cafe".
+func foldText(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+// tokenize folds the text and splits it into words made of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(foldText(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
+
+// maxTypos is how many edits a query token of the given length may be away
+// from a word and still match it. Short tokens and numbers must match exactly.
+func maxTypos(token string) int {
+	if hasDigit(token) {
+		return 0
+	}
+
+	switch n := len([]rune(token)); {
+	case n < 4:
+		return 0
+	case n < 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// tokenScore rates how well a query token matches a word: 1 for an exact
+// match, less for a prefix of the word or a match within the typo budget,
+// and 0 for no match. Numbers only match exactly, so "12" does not find "1285".
+func tokenScore(query, word string) float64 {
+	if query == word {
+		return 1
+	}
+	if hasDigit(query) {
+		return 0
+	}
+	if len(query) >= 2 && strings.HasPrefix(word, query) {
+		return 0.8
+	}
+
+	limit := maxTypos(query)
+	if limit == 0 {
+		return 0
+	}
+	if distance := editDistance(query, word, limit); distance <= limit {
+		return 0.7 - 0.15*float64(distance)
+	}
+	return 0
+}
+
+// editDistance returns the Damerau-Levenshtein (optimal string alignment)
+// distance between a and b, or limit+1 once it is known to exceed limit.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	// Three rolling rows are enough for adjacent transpositions
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// textScore rates how well the query tokens match the restaurant's name,
+// cuisine and address. Every token has to match somewhere, otherwise the
+// score is 0; higher scores are more relevant.
+func textScore(queryTokens []string, r models.Restaurant) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{tokenize(r.Name), nameWeight},
+		{tokenize(r.CuisineType), cuisineWeight},
+		{tokenize(r.Address), addressWeight},
+	}
+
+	total := 0.0
+	for _, token := range queryTokens {
+		best := 0.0
+		for _, field := range fields {
+			for _, word := range field.words {
+				best = max(best, tokenScore(token, word)*field.weight)
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+
+	return total
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"server/models"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"abc", "abc", 2, 0},
+		{"kitten", "sitting", 3, 3},
+		{"flaw", "lawn", 2, 2},
+		{"abcd", "abdc", 2, 1}, // adjacent transposition
+		{"café", "cafe", 1, 1}, // runes, not bytes
+		{"", "abc", 5, 3},
+		{"abc", "", 5, 3},
+		{"abc", "abcdef", 1, 2},     // length difference over the limit
+		{"aaaa", "bbbb", 1, 2},      // stops once the limit is exceeded
+		{"kitten", "sitting", 2, 3}, // limit+1, not the real distance
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTextScore(t *testing.T) {
+	r := models.Restaurant{
+		Name:        "Falafel King Café",
+		CuisineType: "Middle Eastern",
+		Address:     "12 Main St, Tel Aviv, Israel",
+	}
+
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"falafel", true},
+		{"FALAFEL", true},
+		{"fala", true},    // prefix
+		{"falafle", true}, // transposition
+		{"falafl", true},  // deletion
+		{"kinf", true},    // one typo in a four-letter word
+		{"cafe", true},    // diacritics folded
+		{"middle eastern", true},
+		{"falafel aviv", true}, // tokens may match different fields
+		{"12", true},
+		{"1", false}, // numbers match exactly
+		{"13", false},
+		{"kin", true},            // prefix of at least two letters
+		{"kib", false},           // no typos in short words
+		{"falafel pizza", false}, // every token must match
+		{"sushi", false},
+	}
+
+	for _, tt := range tests {
+		score := textScore(tokenize(tt.query), r)
+		if (score > 0) != tt.match {
+			t.Errorf("textScore(%q) = %v, want a match: %v", tt.query, score, tt.match)
+		}
+	}
+
+	// More exact matches and more important fields score higher
+	ordered := []string{"falafel", "fala", "falafle", "aviv"}
+	for i := 1; i < len(ordered); i++ {
+		better := textScore(tokenize(ordered[i-1]), r)
+		worse := textScore(tokenize(ordered[i]), r)
+		if better <= worse {
+			t.Errorf("textScore(%q) = %v, want more than textScore(%q) = %v", ordered[i-1], better, ordered[i], worse)
+		}
+	}
+}
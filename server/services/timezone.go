@@ -0,0 +1,77 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"server/models"
+)
+
+var (
+	timezonesMu sync.RWMutex
+	timezones   = map[string]*time.Location{}
+)
+
+// loadTimezone resolves an IANA timezone name, caching the result since
+// time.LoadLocation reads the zoneinfo database on every call.
+func loadTimezone(name string) (*time.Location, error) {
+	timezonesMu.RLock()
+	loc, ok := timezones[name]
+	timezonesMu.RUnlock()
+	if ok {
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	timezonesMu.Lock()
+	timezones[name] = loc
+	timezonesMu.Unlock()
+
+	return loc, nil
+}
+
+// restaurantTimezone returns the restaurant's own timezone. Restaurants
+// without a valid timezone fall back to the server's local zone.
+func restaurantTimezone(r models.Restaurant) *time.Location {
+	if r.Timezone == "" {
+		logTimezoneFallback(r, "no timezone")
+		return time.Local
+	}
+
+	loc, err := loadTimezone(r.Timezone)
+	if err != nil {
+		logTimezoneFallback(r, err.Error())
+		return time.Local
+	}
+	return loc
+}
+
+var (
+	fallbacksMu sync.Mutex
+	fallbacks   = map[string]bool{} // restaurant ID and timezone
+)
+
+// logTimezoneFallback logs that the restaurant's hours are evaluated in the
+// server's local zone, once per restaurant and timezone, since every search
+// evaluates them again.
+func logTimezoneFallback(r models.Restaurant, reason string) {
+	key := r.RestaurantID + "\x00" + r.Timezone
+	fallbacksMu.Lock()
+	logged := fallbacks[key]
+	fallbacks[key] = true
+	fallbacksMu.Unlock()
+
+	if !logged {
+		log.Printf("Restaurant %s has %s, evaluating its hours in the server's local zone", r.RestaurantID, reason)
+	}
+}
+
+// restaurantTime converts t to the restaurant's own timezone.
+func restaurantTime(r models.Restaurant, t time.Time) time.Time {
+	return t.In(restaurantTimezone(r))
+}
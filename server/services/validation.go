@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"server/models"
+)
+
+// ValidateRestaurant checks the fields the search logic depends on before a restaurant is stored.
+func ValidateRestaurant(r models.Restaurant) error {
+	// Opening hours are local times, so they mean nothing without the
+	// restaurant's own zone. "Local" would silently be the server's zone.
+	if r.Timezone == "" {
+		return fmt.Errorf("timezone is required: must be an IANA name such as America/New_York")
+	}
+	if _, err := loadTimezone(r.Timezone); err != nil || r.Timezone == "Local" {
+		return fmt.Errorf("invalid timezone %q: must be an IANA name such as America/New_York", r.Timezone)
+	}
+
+	if r.Location != nil {
+		if r.Location.Latitude < -90 || r.Location.Latitude > 90 {
+			return fmt.Errorf("invalid latitude %v", r.Location.Latitude)
+		}
+		if r.Location.Longitude < -180 || r.Location.Longitude > 180 {
+			return fmt.Errorf("invalid longitude %v", r.Location.Longitude)
+		}
+	}
+
+	for _, tag := range r.DietaryTags {
+		if !models.IsDietaryTag(tag) {
+			return fmt.Errorf("invalid dietary tag %q: must be one of %s", tag, strings.Join(models.DietaryTags, ", "))
+		}
+	}
+
+	// Kosher searches only match certified restaurants, so the flag and the
+	// certificate must come together
+	if r.IsKosher && r.KosherCertification == nil {
+		return fmt.Errorf("kosher restaurants need a kosher certification")
+	}
+	if !r.IsKosher && r.KosherCertification != nil {
+		return fmt.Errorf("kosher certification is only allowed for kosher restaurants")
+	}
+
+	if cert := r.KosherCertification; cert != nil {
+		if strings.TrimSpace(cert.Agency) == "" {
+			return fmt.Errorf("kosher certification agency is required")
+		}
+		switch cert.Level {
+		case models.KosherMeat, models.KosherDairy, models.KosherParve:
+		default:
+			return fmt.Errorf("invalid kosher certification level %q: must be %s, %s or %s", cert.Level, models.KosherMeat, models.KosherDairy, models.KosherParve)
+		}
+		if _, err := time.Parse(models.DateLayout, cert.ExpiresOn); err != nil {
+			return fmt.Errorf("invalid kosher certification expiry %q: must be YYYY-MM-DD", cert.ExpiresOn)
+		}
+	}
+
+	if r.PriceLevel != 0 && (r.PriceLevel < models.MinPriceLevel || r.PriceLevel > models.MaxPriceLevel) {
+		return fmt.Errorf("invalid price level %d: must be between %d and %d", r.PriceLevel, models.MinPriceLevel, models.MaxPriceLevel)
+	}
+
+	if err := ValidateStatus(r.Status, r.ReopenDate); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(r.SpecialHours))
+	for _, special := range r.SpecialHours {
+		if _, err := time.Parse(models.DateLayout, special.Date); err != nil {
+			return fmt.Errorf("invalid special hours date %q: must be YYYY-MM-DD", special.Date)
+		}
+		if special.Hours == nil {
+			return fmt.Errorf("special hours for %s have no hours: use \"Closed\" to close for the day", special.Date)
+		}
+		if seen[special.Date] {
+			return fmt.Errorf("duplicate special hours for %s", special.Date)
+		}
+		seen[special.Date] = true
+	}
+
+	return nil
+}
+
+// ValidateStatus checks a restaurant status and its optional reopen date.
+func ValidateStatus(status, reopenDate string) error {
+	switch status {
+	case "", models.StatusActive, models.StatusPermanentlyClosed:
+		if reopenDate != "" {
+			return fmt.Errorf("reopen date is only allowed for status %q", models.StatusTemporarilyClosed)
+		}
+	case models.StatusTemporarilyClosed:
+		if reopenDate != "" {
+			if _, err := time.Parse(models.DateLayout, reopenDate); err != nil {
+				return fmt.Errorf("invalid reopen date %q: must be YYYY-MM-DD", reopenDate)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid status %q", status)
+	}
+	return nil
+}
+
+// ValidateReviewStatus checks a moderation decision. Rejections need a reason.
+func ValidateReviewStatus(status, reason string) error {
+	switch status {
+	case models.ReviewApproved:
+	case models.ReviewRejected:
+		if strings.TrimSpace(reason) == "" {
+			return fmt.Errorf("a reason is required to reject a review")
+		}
+	default:
+		return fmt.Errorf("invalid status %q: must be %q or %q", status, models.ReviewApproved, models.ReviewRejected)
+	}
+	if len([]rune(reason)) > models.MaxReviewTextLength {
+		return fmt.Errorf("reason must be at most %d characters", models.MaxReviewTextLength)
+	}
+	return nil
+}
+
+// ValidateReview checks a review posted by a customer.
+func ValidateReview(review models.Review) error {
+	if review.Rating < models.MinRating || review.Rating > models.MaxRating {
+		return fmt.Errorf("invalid rating %d: must be between %d and %d", review.Rating, models.MinRating, models.MaxRating)
+	}
+	if strings.TrimSpace(review.Author) == "" {
+		return fmt.Errorf("author is required")
+	}
+	if len([]rune(review.Author)) > models.MaxReviewAuthorLength {
+		return fmt.Errorf("author must be at most %d characters", models.MaxReviewAuthorLength)
+	}
+	if len([]rune(review.Text)) > models.MaxReviewTextLength {
+		return fmt.Errorf("text must be at most %d characters", models.MaxReviewTextLength)
+	}
+	return nil
+}
@@ -6,7 +6,7 @@ document.getElementById("login-form").addEventListener("submit", async (e) => {
 
     try {
         // Perform a request to validate the admin password
-        const response = await fetch("/admin/validate", {
+        const response = await fetch("/v1/admin/validate", {
             method: "GET",
             headers: { Authorization: password },
         });
@@ -27,6 +27,43 @@ document.getElementById("login-form").addEventListener("submit", async (e) => {
     }
 });
 
+// Build a location object from the latitude/longitude inputs, or null if either is empty
+function readLocation(latitudeId, longitudeId) {
+    const lat = document.getElementById(latitudeId).value;
+    const lng = document.getElementById(longitudeId).value;
+    if (lat === "" || lng === "") {
+        return null;
+    }
+    return { lat: parseFloat(lat), lng: parseFloat(lng) };
+}
+
+// Collect the values of the checked dietary tag checkboxes with the given name
+function readDietaryTags(name) {
+    return Array.from(
+        document.querySelectorAll(`input[name="${name}"]:checked`),
+        (checkbox) => checkbox.value
+    );
+}
+
+// Build a kosher certification from the inputs with the given id prefix, or null if no agency is given
+function readKosherCertification(prefix) {
+    const agency = document.getElementById(`${prefix}kosher_agency`).value;
+    if (agency === "") {
+        return null;
+    }
+    return {
+        agency: agency,
+        level: document.getElementById(`${prefix}kosher_level`).value,
+        expires_on: document.getElementById(`${prefix}kosher_expires_on`).value,
+    };
+}
+
+// Read a numeric input, or 0 (unknown) if it is empty
+function readNumber(id) {
+    const value = document.getElementById(id).value;
+    return value === "" ? 0 : Number(value);
+}
+
 // Add Restaurant Form Submission Handler
 document.getElementById("add-restaurant-form").addEventListener("submit", async (e) => {
     e.preventDefault(); // Prevent default form submission behavior
@@ -34,10 +71,19 @@ document.getElementById("add-restaurant-form").addEventListener("submit", async
     const restaurant = {
         restaurant_name: document.getElementById("restaurant_name").value,
         address: document.getElementById("address").value,
+        city: document.getElementById("city").value,
+        state: document.getElementById("state").value,
+        postal_code: document.getElementById("postal_code").value,
+        country: document.getElementById("country").value,
+        location: readLocation("latitude", "longitude"),
+        timezone: document.getElementById("timezone").value,
         phone: document.getElementById("phone").value,
         website: document.getElementById("website").value,
         cuisine_type: document.getElementById("cuisine_type").value,
+        price_level: readNumber("price_level"),
         is_kosher: document.getElementById("is_kosher").checked,
+        kosher_certification: readKosherCertification(""),
+        dietary_tags: readDietaryTags("dietary_tags"),
         opening_hours: {
             Monday: document.getElementById("monday").value,
             Tuesday: document.getElementById("tuesday").value,
@@ -53,7 +99,7 @@ document.getElementById("add-restaurant-form").addEventListener("submit", async
 
     try {
         // Send POST request to add a new restaurant
-        const response = await fetch("/admin/restaurants", {
+        const response = await fetch("/v1/admin/restaurants", {
             method: "POST",
             headers: {
                 Authorization: password,
@@ -66,7 +112,8 @@ document.getElementById("add-restaurant-form").addEventListener("submit", async
             alert("Restaurant added successfully");
             document.getElementById("add-restaurant-form").reset(); // Clear form after successful submission
         } else {
-            alert("Failed to add restaurant");
+            const problem = await response.json();
+            alert(`Failed to add restaurant: ${(problem.errors || [problem.detail]).join(", ")}`);
         }
     } catch (error) {
         console.error("Error adding restaurant:", error);
@@ -85,25 +132,51 @@ document.getElementById("fetch-restaurant-btn").addEventListener("click", async
     }
 
     try {
-        const response = await fetch(`/admin/restaurants/${restaurantID}`, {
+        const response = await fetch(`/v1/admin/restaurants/${restaurantID}`, {
             headers: { Authorization: password },
         });
 
         if (response.ok) {
-            const restaurant = await response.json();
+            const restaurant = (await response.json()).data;
 
             // Populate the form fields with the retrieved data
             document.getElementById("edit_restaurant_name").value =
                 restaurant.restaurant_name || "";
             document.getElementById("edit_address").value =
                 restaurant.address || "";
+            document.getElementById("edit_city").value = restaurant.city || "";
+            document.getElementById("edit_state").value = restaurant.state || "";
+            document.getElementById("edit_postal_code").value =
+                restaurant.postal_code || "";
+            document.getElementById("edit_country").value =
+                restaurant.country || "";
+            const location = restaurant.location || {};
+            document.getElementById("edit_latitude").value = location.lat ?? "";
+            document.getElementById("edit_longitude").value = location.lng ?? "";
+            document.getElementById("edit_timezone").value =
+                restaurant.timezone || "";
             document.getElementById("edit_phone").value = restaurant.phone || "";
             document.getElementById("edit_website").value =
                 restaurant.website || "";
             document.getElementById("edit_cuisine_type").value =
                 restaurant.cuisine_type || "";
+            document.getElementById("edit_price_level").value =
+                restaurant.price_level || "";
             document.getElementById("edit_is_kosher").checked =
                 restaurant.is_kosher || false;
+            const certification = restaurant.kosher_certification || {};
+            document.getElementById("edit_kosher_agency").value =
+                certification.agency || "";
+            document.getElementById("edit_kosher_level").value =
+                certification.level || "";
+            document.getElementById("edit_kosher_expires_on").value =
+                certification.expires_on || "";
+            const dietaryTags = restaurant.dietary_tags || [];
+            document
+                .querySelectorAll('input[name="edit_dietary_tags"]')
+                .forEach((checkbox) => {
+                    checkbox.checked = dietaryTags.includes(checkbox.value);
+                });
 
             const openingHours = restaurant.opening_hours || {};
             document.getElementById("edit_monday").value =
@@ -132,6 +205,105 @@ document.getElementById("fetch-restaurant-btn").addEventListener("click", async
     }
 });
 
+// Cursor for the next page of pending reviews, or empty when there are no more
+let pendingReviewsCursor = "";
+
+// Append a page of pending reviews to the moderation table
+async function loadPendingReviews(reset) {
+    const password = localStorage.getItem("admin-password"); // Retrieve stored password
+
+    if (!password) {
+        alert("Please log in first.");
+        return;
+    }
+
+    const cursor = reset ? "" : pendingReviewsCursor;
+    try {
+        const response = await fetch(`/v1/admin/reviews?cursor=${encodeURIComponent(cursor)}`, {
+            headers: { Authorization: password },
+        });
+
+        if (response.ok) {
+            const page = await response.json();
+            const tbody = document.getElementById("pending-reviews-table").querySelector("tbody");
+            if (reset) {
+                tbody.innerHTML = ""; // Clear previous reviews
+            }
+
+            // Review text comes from customers, so cells are filled with textContent
+            page.data.forEach((review) => {
+                const row = tbody.insertRow();
+                [review.created_at, review.restaurant_id, review.author, review.rating, review.text].forEach((value) => {
+                    row.insertCell().textContent = value;
+                });
+
+                const reason = document.createElement("input");
+                reason.type = "text";
+                reason.placeholder = "Required to reject";
+                row.insertCell().appendChild(reason);
+
+                const actions = row.insertCell();
+                const approve = document.createElement("button");
+                approve.textContent = "Approve";
+                approve.addEventListener("click", () => moderateReview(review, "approved", reason.value, row));
+                const reject = document.createElement("button");
+                reject.textContent = "Reject";
+                reject.addEventListener("click", () => moderateReview(review, "rejected", reason.value, row));
+                actions.append(approve, reject);
+            });
+
+            pendingReviewsCursor = page.pagination.next_cursor || "";
+            const empty = tbody.rows.length === 0;
+            document.getElementById("pending-reviews-table").style.display = empty ? "none" : "table";
+            document.getElementById("pending-reviews-empty").style.display = empty ? "block" : "none";
+            document.getElementById("more-pending-reviews-btn").style.display = pendingReviewsCursor ? "inline" : "none";
+        } else {
+            alert("Failed to fetch pending reviews.");
+        }
+    } catch (error) {
+        console.error("Error fetching pending reviews:", error);
+        alert("Failed to fetch pending reviews.");
+    }
+}
+
+// Approve or reject a review and remove it from the moderation table
+async function moderateReview(review, status, reason, row) {
+    const password = localStorage.getItem("admin-password"); // Retrieve stored password
+
+    if (status === "rejected" && reason.trim() === "") {
+        alert("Please enter a reason for rejecting the review.");
+        return;
+    }
+
+    try {
+        const response = await fetch(
+            `/v1/admin/restaurants/${encodeURIComponent(review.restaurant_id)}/reviews/${encodeURIComponent(review.review_id)}/status`,
+            {
+                method: "PUT",
+                headers: {
+                    "Content-Type": "application/json",
+                    Authorization: password,
+                },
+                body: JSON.stringify({ status: status, status_reason: reason }),
+            }
+        );
+
+        if (response.ok || response.status === 409) {
+            // A conflict means another admin already moderated the review
+            row.remove();
+        } else {
+            const problem = await response.json();
+            alert(`Error: ${problem.detail || "Failed to update review status"}`);
+        }
+    } catch (error) {
+        console.error("Error moderating review:", error);
+        alert("Failed to update review status.");
+    }
+}
+
+document.getElementById("fetch-pending-reviews-btn").addEventListener("click", () => loadPendingReviews(true));
+document.getElementById("more-pending-reviews-btn").addEventListener("click", () => loadPendingReviews(false));
+
 // Fetch Audit Logs Button Handler
 document.getElementById("fetch-audit-logs-btn").addEventListener("click", async () => {
     const minutes = document.getElementById("log-minutes").value || 1440; // Default to 1440 (24 hours)
@@ -144,12 +316,12 @@ document.getElementById("fetch-audit-logs-btn").addEventListener("click", async
 
     try {
         // Send GET request to fetch audit logs
-        const response = await fetch(`/admin/logs?minutes=${minutes}`, {
+        const response = await fetch(`/v1/admin/logs?minutes=${minutes}`, {
             headers: { Authorization: password },
         });
 
         if (response.ok) {
-            const logs = await response.json();
+            const logs = (await response.json()).data;
             const tbody = document.getElementById("audit-log-table").querySelector("tbody");
             tbody.innerHTML = ""; // Clear previous logs
 
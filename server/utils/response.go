@@ -1,9 +1,131 @@
 package utils
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
+// V1 is the prefix of the versioned API. Routes outside it are the original,
+// deprecated API and keep their original response bodies.
+const V1 = "/v1"
+
+const v1Key = "api_v1"
+
+// Envelope is the body of every successful /v1 response.
+type Envelope struct {
+	Data       interface{} `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	Meta       interface{} `json:"meta,omitempty"`
+}
+
+// Pagination is set on paginated /v1 responses. NextCursor is null on the
+// last page.
+type Pagination struct {
+	NextCursor *string `json:"next_cursor"`
+}
+
+// Problem is the RFC 7807 body of every /v1 error response. Errors lists
+// further details, such as why a request body failed validation.
+type Problem struct {
+	Type     string   `json:"type"`
+	Title    string   `json:"title"`
+	Status   int      `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// UseV1 marks the requests of a route group as /v1 requests, which selects
+// how Respond and RespondError write their bodies.
+func UseV1() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(v1Key, true)
+		c.Next()
+	}
+}
+
+// Deprecated marks the responses of a route group as deprecated since the
+// given time and to be removed at sunset, pointing clients at the same path
+// under the successor prefix. The headers follow RFC 9745 and RFC 8594.
+func Deprecated(successor string, since, sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetDate)
+		c.Header("Link", "<"+successor+c.Request.URL.Path+">; rel=\"successor-version\"")
+		c.Next()
+	}
+}
+
+// IsV1 reports whether the request was routed through the /v1 API.
+func IsV1(c *gin.Context) bool {
+	return c.GetBool(v1Key)
+}
+
+// Wrap returns the body Respond would write for the payload.
+func Wrap(c *gin.Context, payload interface{}) interface{} {
+	if IsV1(c) {
+		return Envelope{Data: payload}
+	}
+	return payload
+}
+
+// Respond writes a successful response: the payload itself on the original
+// API, or the payload wrapped in an Envelope on /v1.
 func Respond(c *gin.Context, status int, payload interface{}) {
-	c.JSON(status, payload)
+	c.JSON(status, Wrap(c, payload))
+}
+
+// RespondPage writes one page of a paginated listing. The original API gets
+// page as it is; /v1 gets items as data, the cursor as pagination and meta.
+func RespondPage(c *gin.Context, page, items interface{}, nextCursor string, meta interface{}) {
+	if !IsV1(c) {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	pagination := &Pagination{}
+	if nextCursor != "" {
+		pagination.NextCursor = &nextCursor
+	}
+	c.JSON(http.StatusOK, Envelope{Data: items, Pagination: pagination, Meta: meta})
+}
+
+// RespondError writes an error response and aborts the request. The original
+// API gets {"error": message, "details": details}; /v1 gets a Problem.
+func RespondError(c *gin.Context, status int, message string, details ...string) {
+	if !IsV1(c) {
+		body := gin.H{"error": message}
+		if len(details) > 0 {
+			body["details"] = strings.Join(details, "; ")
+		}
+		c.AbortWithStatusJSON(status, body)
+		return
+	}
+
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   message,
+		Instance: c.Request.URL.Path,
+		Errors:   details,
+	})
+}
+
+// NoRoute answers requests for unknown paths, with a Problem under /v1 and
+// gin's default plain text elsewhere.
+func NoRoute(c *gin.Context) {
+	if !strings.HasPrefix(c.Request.URL.Path, V1+"/") {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+	c.Set(v1Key, true)
+	RespondError(c, http.StatusNotFound, "No route matches the requested path.")
 }